@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+const (
+	auditLogin = "user.login"
+	auditLoginFailed = "user.login_failed"
+	auditUserUpdated = "user.updated"
+	auditUserUpgraded = "user.upgraded"
+	auditUserDeleted = "user.deleted"
+	auditRoleChanged = "user.role_changed"
+	auditPasswordReset = "user.password_reset"
+	auditTokenRevoked = "token.revoked"
+	auditSessionsRevoked = "user.sessions_revoked"
+	auditChirpDeleted = "chirp.deleted"
+	auditChirpHidden = "chirp.hidden"
+	auditUserSuspended = "user.suspended"
+	auditUserStatusChanged = "user.status_changed"
+	auditReportResolved = "report.resolved"
+	auditFilterRuleSet = "filter.rule_set"
+	auditFilterRuleDeleted = "filter.rule_deleted"
+	auditWebhookCreated = "webhook.created"
+	auditWebhookDeleted = "webhook.deleted"
+	auditJobRetried = "job.retried"
+	auditJobDeleted = "job.deleted"
+)
+
+type auditEntry struct {
+	Action		string
+	ActorID		uuid.UUID
+	TargetType	string
+	TargetID	string
+	Metadata	map[string]any
+}
+
+// recordAudit appends an entry to audit_events. r may be nil for actions that
+// don't come from an HTTP request, such as operator commands. Failures are
+// logged rather than failing the action being audited.
+func recordAudit(ctx context.Context, db *database.Queries, r *http.Request, entry auditEntry) {
+	metadata := []byte("{}")
+	if len(entry.Metadata) > 0 {
+		dat, err := json.Marshal(entry.Metadata)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to encode audit metadata", "action", entry.Action, "error", err)
+		} else {
+			metadata = dat
+		}
+	}
+
+	params := database.CreateAuditEventParams{
+		Action: entry.Action,
+		ActorID: uuid.NullUUID{UUID: entry.ActorID, Valid: entry.ActorID != uuid.Nil},
+		TargetType: entry.TargetType,
+		TargetID: entry.TargetID,
+		Metadata: metadata,
+	}
+	if r != nil {
+		params.Ip = clientIP(r)
+		params.UserAgent = r.UserAgent()
+	}
+
+	if err := db.CreateAuditEvent(ctx, params); err != nil {
+		slog.ErrorContext(ctx, "failed to record audit event", "action", entry.Action, "error", err)
+	}
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
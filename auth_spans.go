@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/auth"
+	"github.com/ppllama/chirpy/internal/logging"
+	"github.com/ppllama/chirpy/internal/tracing"
+)
+
+// The wrappers below put the CPU-heavy auth calls in their own spans so slow
+// requests can be attributed to argon2 or JWT handling.
+
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Start(ctx, "auth.HashPassword")
+	defer span.End()
+	return auth.HashPassword(password)
+}
+
+func checkPasswordHash(ctx context.Context, password, hash string) (bool, error) {
+	_, span := tracing.Start(ctx, "auth.CheckPasswordHash")
+	defer span.End()
+	return auth.CheckPasswordHash(password, hash)
+}
+
+func makeJWT(ctx context.Context, userID uuid.UUID, role string, tokenSecret string) (string, error) {
+	_, span := tracing.Start(ctx, "auth.MakeJWT")
+	defer span.End()
+	return auth.MakeJWT(userID, role, tokenSecret)
+}
+
+// validateJWT also records the authenticated user on the request context so
+// it shows up in log lines.
+func validateJWT(ctx context.Context, tokenString, tokenSecret string) (uuid.UUID, error) {
+	userID, _, err := validateJWTWithRole(ctx, tokenString, tokenSecret)
+	return userID, err
+}
+
+func validateJWTWithRole(ctx context.Context, tokenString, tokenSecret string) (uuid.UUID, string, error) {
+	_, span := tracing.Start(ctx, "auth.ValidateJWT")
+	defer span.End()
+	userID, role, err := auth.ValidateJWTWithRole(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	logging.SetUserID(ctx, userID)
+	return userID, role, nil
+}
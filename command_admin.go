@@ -0,0 +1,662 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/auth"
+	"github.com/ppllama/chirpy/internal/config"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+// Operator commands. They talk to the database directly through
+// database.Queries and identify users by -email or -id.
+
+type adminCommand struct {
+	flags *flag.FlagSet
+	email *string
+	id    *string
+}
+
+func newAdminCommand(name string) *adminCommand {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	return &adminCommand{
+		flags: flags,
+		email: flags.String("email", "", "email of the user"),
+		id:    flags.String("id", "", "ID of the user"),
+	}
+}
+
+func openQueries(appConfig *config.Config) (*database.Queries, func() error, error) {
+	dbConn, err := sql.Open("postgres", appConfig.DBURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open db: %w", err)
+	}
+	return database.New(dbConn), dbConn.Close, nil
+}
+
+func(c *adminCommand) lookupUser(ctx context.Context, db *database.Queries) (database.User, error) {
+	var user database.User
+	var err error
+	switch {
+	case *c.id != "":
+		id, parseErr := uuid.Parse(*c.id)
+		if parseErr != nil {
+			return database.User{}, fmt.Errorf("invalid -id: %w", parseErr)
+		}
+		user, err = db.GetUserByID(ctx, id)
+	case *c.email != "":
+		user, err = db.GetUser(ctx, *c.email)
+	default:
+		return database.User{}, fmt.Errorf("-email or -id is required")
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, fmt.Errorf("user not found")
+	}
+	return user, err
+}
+
+// recordCLIAudit records an operator action. There is no authenticated actor,
+// so the entry is tagged with the command source instead.
+func recordCLIAudit(ctx context.Context, db *database.Queries, action string, target uuid.UUID, metadata map[string]any) {
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
+	metadata["source"] = "cli"
+	recordAudit(ctx, db, nil, auditEntry{
+		Action: action,
+		TargetType: "user",
+		TargetID: target.String(),
+		Metadata: metadata,
+	})
+}
+
+// readPassword returns flagValue if set, otherwise the first line of stdin,
+// so passwords can be piped in rather than left in shell history.
+func readPassword(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("password must not be empty")
+	}
+	return password, nil
+}
+
+func runCreateUser(appConfig *config.Config, args []string) error {
+	flags := flag.NewFlagSet("create-user", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the new user")
+	password := flags.String("password", "", "password (read from stdin if omitted)")
+	role := flags.String("role", auth.RoleUser, "role: user, moderator or admin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return fmt.Errorf("-email is required")
+	}
+	if !auth.ValidRole(*role) {
+		return fmt.Errorf("invalid -role %q", *role)
+	}
+
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := auth.HashPassword(pw)
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{
+		Email: *email,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		return err
+	}
+	if *role != auth.RoleUser {
+		if err := db.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: *role}); err != nil {
+			return err
+		}
+		recordCLIAudit(ctx, db, auditRoleChanged, user.ID, map[string]any{"old_role": user.Role, "new_role": *role})
+	}
+	fmt.Printf("Created %s %s (%s)\n", *role, user.Email, user.ID)
+	return nil
+}
+
+// runSetRole is also how the first admin is bootstrapped: create an account
+// through the API or create-user, then promote it here.
+func runSetRole(appConfig *config.Config, args []string) error {
+	cmd := newAdminCommand("set-role")
+	role := cmd.flags.String("role", "", "role: user, moderator or admin")
+	if err := cmd.flags.Parse(args); err != nil {
+		return err
+	}
+	if !auth.ValidRole(*role) {
+		return fmt.Errorf("invalid -role %q", *role)
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	user, err := cmd.lookupUser(ctx, db)
+	if err != nil {
+		return err
+	}
+	if err := db.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: *role}); err != nil {
+		return err
+	}
+	recordCLIAudit(ctx, db, auditRoleChanged, user.ID, map[string]any{"old_role": user.Role, "new_role": *role})
+	fmt.Printf("%s is now %s\n", user.Email, *role)
+	return nil
+}
+
+func runSetStatus(appConfig *config.Config, args []string) error {
+	cmd := newAdminCommand("set-status")
+	status := cmd.flags.String("status", "", "status: active, limited or suspended")
+	reason := cmd.flags.String("reason", "", "reason recorded with the status")
+	duration := cmd.flags.Duration("for", 0, "lift the status automatically after this long")
+	if err := cmd.flags.Parse(args); err != nil {
+		return err
+	}
+	if !validUserStatus(*status) {
+		return fmt.Errorf("invalid -status %q", *status)
+	}
+	if *status != userStatusActive && *reason == "" {
+		return fmt.Errorf("-reason is required")
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	user, err := cmd.lookupUser(ctx, db)
+	if err != nil {
+		return err
+	}
+	if err := setUserStatus(ctx, db, user.ID, *status, *reason, *duration); err != nil {
+		return err
+	}
+	metadata := map[string]any{"old_status": user.Status, "new_status": *status}
+	if *reason != "" {
+		metadata["reason"] = *reason
+	}
+	if *duration > 0 {
+		metadata["duration"] = duration.String()
+	}
+	recordCLIAudit(ctx, db, auditUserStatusChanged, user.ID, metadata)
+	fmt.Printf("%s is now %s\n", user.Email, *status)
+	return nil
+}
+
+func runSetPassword(appConfig *config.Config, args []string) error {
+	cmd := newAdminCommand("set-password")
+	password := cmd.flags.String("password", "", "new password (read from stdin if omitted)")
+	if err := cmd.flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	user, err := cmd.lookupUser(ctx, db)
+	if err != nil {
+		return err
+	}
+	pw, err := readPassword(*password)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := auth.HashPassword(pw)
+	if err != nil {
+		return err
+	}
+
+	if err := db.SetUserPassword(ctx, database.SetUserPasswordParams{
+		ID: user.ID,
+		HashedPassword: hashedPassword,
+	}); err != nil {
+		return err
+	}
+	recordCLIAudit(ctx, db, auditPasswordReset, user.ID, nil)
+	fmt.Printf("Updated password for %s\n", user.Email)
+	return nil
+}
+
+func runGrantRed(appConfig *config.Config, args []string) error {
+	cmd := newAdminCommand("grant-red")
+	if err := cmd.flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	user, err := cmd.lookupUser(ctx, db)
+	if err != nil {
+		return err
+	}
+	if err := db.UpgradeUser(ctx, user.ID); err != nil {
+		return err
+	}
+	recordCLIAudit(ctx, db, auditUserUpgraded, user.ID, nil)
+	fmt.Printf("Granted Chirpy Red to %s\n", user.Email)
+	return nil
+}
+
+func runRevokeSessions(appConfig *config.Config, args []string) error {
+	cmd := newAdminCommand("revoke-sessions")
+	if err := cmd.flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	user, err := cmd.lookupUser(ctx, db)
+	if err != nil {
+		return err
+	}
+	revoked, err := db.RevokeUserRefreshTokens(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	recordCLIAudit(ctx, db, auditSessionsRevoked, user.ID, map[string]any{"revoked": revoked})
+	fmt.Printf("Revoked %d refresh tokens for %s\n", revoked, user.Email)
+	return nil
+}
+
+func runDeleteUser(appConfig *config.Config, args []string) error {
+	cmd := newAdminCommand("delete-user")
+	yes := cmd.flags.Bool("yes", false, "confirm deleting the user and all of their chirps")
+	if err := cmd.flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	user, err := cmd.lookupUser(ctx, db)
+	if err != nil {
+		return err
+	}
+	if !*yes {
+		return fmt.Errorf("deleting %s also deletes their chirps and sessions; pass -yes to confirm", user.Email)
+	}
+	if err := db.DeleteUser(ctx, user.ID); err != nil {
+		return err
+	}
+	recordCLIAudit(ctx, db, auditUserDeleted, user.ID, map[string]any{"email": user.Email})
+	fmt.Printf("Deleted user %s (%s)\n", user.Email, user.ID)
+	return nil
+}
+
+func runListUsers(appConfig *config.Config, args []string) error {
+	flags := flag.NewFlagSet("list-users", flag.ContinueOnError)
+	limit := flags.Int("limit", 100, "maximum number of users to list")
+	offset := flags.Int("offset", 0, "number of users to skip")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	users, err := db.ListUsers(context.Background(), database.ListUsersParams{
+		Limit: int32(*limit),
+		Offset: int32(*offset),
+	})
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tROLE\tCREATED AT\tCHIRPY RED")
+	for _, user := range users {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", user.ID, user.Email, user.Role, user.CreatedAt.Format(time.DateTime), user.IsChirpyRed)
+	}
+	return tw.Flush()
+}
+
+// exportedChirp is a Chirp with its moderation state, since an export
+// includes chirps moderators have hidden.
+type exportedChirp struct {
+	Chirp
+	HiddenAt *time.Time `json:"hidden_at"`
+}
+
+type exportedSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+type exportedNotification struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Type        string     `json:"type"`
+	ActorID     *uuid.UUID `json:"actor_id"`
+	SubjectType string     `json:"subject_type"`
+	SubjectID   string     `json:"subject_id"`
+	ReadAt      *time.Time `json:"read_at"`
+}
+
+type exportedNotificationPreference struct {
+	Type      string    `json:"type"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// exportedDraft leaves out the warnings a Draft carries, which depend on the
+// content filter rather than on anything stored about the user.
+type exportedDraft struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Body      string    `json:"body"`
+}
+
+type exportedPollVote struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	Option  string    `json:"option"`
+	VotedAt time.Time `json:"voted_at"`
+}
+
+type exportedBookmark struct {
+	ChirpID      uuid.UUID  `json:"chirp_id"`
+	CollectionID *uuid.UUID `json:"collection_id"`
+	BookmarkedAt time.Time  `json:"bookmarked_at"`
+}
+
+type exportedRelationship struct {
+	Kind      string    `json:"kind"`
+	TargetID  uuid.UUID `json:"target_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportedUser struct {
+	ID                      uuid.UUID                        `json:"id"`
+	CreatedAt               time.Time                        `json:"created_at"`
+	UpdatedAt               time.Time                        `json:"updated_at"`
+	Email                   string                           `json:"email"`
+	IsChirpyRed             bool                             `json:"is_chirpy_red"`
+	Role                    string                           `json:"role"`
+	Chirps                  []exportedChirp                  `json:"chirps"`
+	ScheduledChirps         []ScheduledChirp                 `json:"scheduled_chirps"`
+	Drafts                  []exportedDraft                  `json:"drafts"`
+	PollVotes               []exportedPollVote               `json:"poll_votes"`
+	Bookmarks               []exportedBookmark               `json:"bookmarks"`
+	BookmarkCollections     []BookmarkCollection             `json:"bookmark_collections"`
+	Messages                []Message                        `json:"messages"`
+	Notifications           []exportedNotification           `json:"notifications"`
+	NotificationPreferences []exportedNotificationPreference `json:"notification_preferences"`
+	Webhooks                []Webhook                        `json:"webhooks"`
+	Reports                 []Report                         `json:"reports"`
+	Relationships           []exportedRelationship           `json:"relationships"`
+	Sessions                []exportedSession                `json:"sessions"`
+}
+
+// runExportUser writes everything stored about a user as JSON to stdout:
+// their account, chirps, scheduled chirps, drafts, poll votes, bookmarks,
+// the messages they sent, notifications, webhooks, reports they filed,
+// blocks and mutes, and sessions. Password hashes, refresh token values and
+// webhook secrets are left out, as are other people's messages to them.
+func runExportUser(appConfig *config.Config, args []string) error {
+	cmd := newAdminCommand("export-user")
+	if err := cmd.flags.Parse(args); err != nil {
+		return err
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	ctx := context.Background()
+	user, err := cmd.lookupUser(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	export := exportedUser{
+		ID: user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Email: user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		Role: user.Role,
+		Chirps: []exportedChirp{},
+		ScheduledChirps: []ScheduledChirp{},
+		Drafts: []exportedDraft{},
+		PollVotes: []exportedPollVote{},
+		Bookmarks: []exportedBookmark{},
+		BookmarkCollections: []BookmarkCollection{},
+		Messages: []Message{},
+		Notifications: []exportedNotification{},
+		NotificationPreferences: []exportedNotificationPreference{},
+		Webhooks: []Webhook{},
+		Reports: []Report{},
+		Relationships: []exportedRelationship{},
+		Sessions: []exportedSession{},
+	}
+
+	chirps, err := db.ExportChirpsByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, chirp := range chirps {
+		exported := exportedChirp{
+			Chirp: Chirp{
+				ID: chirp.ID,
+				CreatedAt: chirp.CreatedAt,
+				UpdatedAt: chirp.UpdatedAt,
+				Body: chirp.Body,
+				UserID: chirp.UserID,
+			},
+		}
+		if chirp.HiddenAt.Valid {
+			exported.HiddenAt = &chirp.HiddenAt.Time
+		}
+		export.Chirps = append(export.Chirps, exported)
+	}
+
+	scheduled, err := db.ListScheduledChirps(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, chirp := range scheduled {
+		export.ScheduledChirps = append(export.ScheduledChirps, scheduledChirpFromDB(chirp))
+	}
+
+	drafts, err := db.ListDrafts(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, draft := range drafts {
+		export.Drafts = append(export.Drafts, exportedDraft{
+			ID: draft.ID,
+			CreatedAt: draft.CreatedAt,
+			UpdatedAt: draft.UpdatedAt,
+			Body: draft.Body,
+		})
+	}
+
+	votes, err := db.ExportPollVotesByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, vote := range votes {
+		export.PollVotes = append(export.PollVotes, exportedPollVote{
+			ChirpID: vote.ChirpID,
+			Option: vote.Option,
+			VotedAt: vote.CreatedAt,
+		})
+	}
+
+	bookmarks, err := db.ExportBookmarksByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, bookmark := range bookmarks {
+		exported := exportedBookmark{
+			ChirpID: bookmark.ChirpID,
+			BookmarkedAt: bookmark.CreatedAt,
+		}
+		if bookmark.CollectionID.Valid {
+			exported.CollectionID = &bookmark.CollectionID.UUID
+		}
+		export.Bookmarks = append(export.Bookmarks, exported)
+	}
+
+	collections, err := db.ListBookmarkCollections(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, collection := range collections {
+		export.BookmarkCollections = append(export.BookmarkCollections, BookmarkCollection{
+			ID: collection.ID,
+			CreatedAt: collection.CreatedAt,
+			UpdatedAt: collection.UpdatedAt,
+			Name: collection.Name,
+			BookmarkCount: collection.BookmarkCount,
+		})
+	}
+
+	messages, err := db.ExportMessagesBySender(ctx, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		export.Messages = append(export.Messages, messageFromDB(message))
+	}
+
+	notifications, err := db.ExportNotificationsByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, n := range notifications {
+		exported := exportedNotification{
+			ID: n.ID,
+			CreatedAt: n.CreatedAt,
+			Type: n.Type,
+			SubjectType: n.SubjectType,
+			SubjectID: n.SubjectID,
+		}
+		if n.ActorID.Valid {
+			exported.ActorID = &n.ActorID.UUID
+		}
+		if n.ReadAt.Valid {
+			exported.ReadAt = &n.ReadAt.Time
+		}
+		export.Notifications = append(export.Notifications, exported)
+	}
+
+	preferences, err := db.ListNotificationPreferences(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, preference := range preferences {
+		export.NotificationPreferences = append(export.NotificationPreferences, exportedNotificationPreference{
+			Type: preference.Type,
+			Enabled: preference.Enabled,
+			UpdatedAt: preference.UpdatedAt,
+		})
+	}
+
+	webhooks, err := db.ListWebhooksByOwner(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, hook := range webhooks {
+		export.Webhooks = append(export.Webhooks, webhookFromDB(hook))
+	}
+
+	reports, err := db.ListReportsByReporter(ctx, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		return err
+	}
+	for _, report := range reports {
+		export.Reports = append(export.Reports, reporterView(report))
+	}
+
+	relationships, err := db.ExportUserRelationships(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, relationship := range relationships {
+		export.Relationships = append(export.Relationships, exportedRelationship{
+			Kind: relationship.Kind,
+			TargetID: relationship.TargetID,
+			CreatedAt: relationship.CreatedAt,
+		})
+	}
+
+	tokens, err := db.GetRefreshTokensByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		session := exportedSession{
+			CreatedAt: token.CreatedAt,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if token.RevokedAt.Valid {
+			session.RevokedAt = &token.RevokedAt.Time
+		}
+		export.Sessions = append(export.Sessions, session)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ppllama/chirpy/internal/config"
+	"github.com/ppllama/chirpy/internal/migrate"
+)
+
+func runMigrate(appConfig *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: chirpy migrate up|down|status|version")
+	}
+
+	dbConn, err := sql.Open("postgres", appConfig.DBURL)
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer dbConn.Close()
+
+	migrator, err := migrate.New(dbConn)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		results, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Println("No migrations to apply")
+		}
+		for _, result := range results {
+			fmt.Printf("Applied %s in %s\n", result.Source.Path, result.Duration)
+		}
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %s in %s\n", result.Source.Path, result.Duration)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+		}
+		return tw.Flush()
+	case "version":
+		current, expected, err := migrator.Versions(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("database: %d\ncode: %d\n", current, expected)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+	return nil
+}
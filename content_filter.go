@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/filter"
+)
+
+// filterSource loads the content filter rules from the filter_rules table
+// and, if set, the FILTER_FILE rules file. Both are reread on every reload.
+func filterSource(db *database.Queries, path string) filter.Source {
+	return func(ctx context.Context) ([]filter.Rule, error) {
+		dbRules, err := db.ListFilterRules(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("loading filter rules: %w", err)
+		}
+		rules := make([]filter.Rule, 0, len(dbRules))
+		for _, rule := range dbRules {
+			rules = append(rules, filter.Rule{Word: rule.Word, Action: filter.Action(rule.Action)})
+		}
+
+		if path == "" {
+			return rules, nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("opening filter file: %w", err)
+		}
+		defer f.Close()
+		fileRules, err := filter.ParseRules(f)
+		if err != nil {
+			return nil, fmt.Errorf("parsing filter file %s: %w", path, err)
+		}
+		return append(rules, fileRules...), nil
+	}
+}
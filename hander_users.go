@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/ppllama/chirpy/internal/auth"
 	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/logging"
 )
 
 type parameters struct {
@@ -23,6 +24,7 @@ type User struct {
 		Token			string		`json:"token"`
 		RefreshToken 	string		`json:"refresh_token"`
 		IsChirpyRed		bool		`json:"is_chirpy_red"`
+		Role			string		`json:"role"`
 	}
 
 
@@ -30,13 +32,13 @@ func(cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request) {
 	
 	params, err := getEmailPassword(r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
+	hashedPassword, err := hashPassword(r.Context(), params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error hashing password", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Error hashing password", err)
 		return
 	}
 
@@ -47,7 +49,7 @@ func(cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request) {
 
 	newUser, err := cfg.db.CreateUser(r.Context(), createUserParams)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create user", err)
 		return
 	}
 	
@@ -57,6 +59,7 @@ func(cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt: newUser.UpdatedAt,
 		Email: newUser.Email,
 		IsChirpyRed: newUser.IsChirpyRed,
+		Role: newUser.Role,
 	})
 }
 
@@ -64,27 +67,59 @@ func(cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	
 	params, err := getEmailPassword(r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
 	user, err := cfg.db.GetUser(r.Context(), params.Email)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't get user", err)
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		recordAudit(r.Context(), cfg.db, r, auditEntry{
+			Action: auditLoginFailed,
+			Metadata: map[string]any{"email": params.Email, "reason": "unknown_email"},
+		})
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
 		return
 	}
 
-	ok, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	ok, err := checkPasswordHash(r.Context(), params.Password, user.HashedPassword)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error verifying user", err)
+		cfg.metrics.logins.WithLabelValues("error").Inc()
+		respondWithError(w, r, http.StatusInternalServerError, "Error verifying user", err)
+		return
 	}
 
 	if !ok {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", nil)
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		recordAudit(r.Context(), cfg.db, r, auditEntry{
+			Action: auditLoginFailed,
+			TargetType: "user",
+			TargetID: user.ID.String(),
+			Metadata: map[string]any{"email": params.Email, "reason": "wrong_password"},
+		})
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect email or password", nil)
+		return
+	}
+
+	user, err = cfg.liftExpiredStatus(r.Context(), user)
+	if err != nil {
+		cfg.metrics.logins.WithLabelValues("error").Inc()
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	if user.Status == userStatusSuspended {
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		recordAudit(r.Context(), cfg.db, r, auditEntry{
+			Action: auditLoginFailed,
+			TargetType: "user",
+			TargetID: user.ID.String(),
+			Metadata: map[string]any{"email": params.Email, "reason": "suspended"},
+		})
+		respondWithError(w, r, http.StatusForbidden, suspendedMessage(user), nil)
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.secret)
+	token, err := makeJWT(r.Context(), user.ID, user.Role, cfg.secret)
 	refreshTokenCandidate, err := auth.MakeRefreshToken()
 
 	refreshTokenParams := database.CreateRefreshTokenParams{
@@ -94,9 +129,18 @@ func(cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 
 	refreshToken, err := cfg.db.CreateRefreshToken(r.Context(), refreshTokenParams)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Could not create refresh token", err)
+		cfg.metrics.logins.WithLabelValues("error").Inc()
+		respondWithError(w, r, http.StatusInternalServerError, "Could not create refresh token", err)
 		return
 	}
+	cfg.metrics.logins.WithLabelValues("success").Inc()
+	logging.SetUserID(r.Context(), user.ID)
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditLogin,
+		ActorID: user.ID,
+		TargetType: "user",
+		TargetID: user.ID.String(),
+	})
 
 	respondWithJSON(w, http.StatusOK, User{
 		ID: user.ID,
@@ -106,25 +150,35 @@ func(cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		Token: token,
 		RefreshToken: refreshToken.Token,
 		IsChirpyRed: user.IsChirpyRed,
+		Role: user.Role,
 	})
 }
 
 func(cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
 		return
 	}
 
 	refreshToken, err := cfg.db.GetUserFromRefreshToken(r.Context(), token)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
+		return
+	}
+
+	logging.SetUserID(r.Context(), refreshToken.UserID)
+
+	// Look the user up again so role changes take effect on refresh.
+	user, err := cfg.db.GetUserByID(r.Context(), refreshToken.UserID)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
 		return
 	}
 
-	newAccessToken, err := auth.MakeJWT(refreshToken.UserID, cfg.secret)
+	newAccessToken, err := makeJWT(r.Context(), user.ID, user.Role, cfg.secret)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error creating new access token", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Error creating new access token", err)
 		return
 	}
 
@@ -152,15 +206,30 @@ func getEmailPassword(r *http.Request) (parameters, error) {
 func(cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
 		return
 	}
 
+	// Only needed to attribute the audit entry; revoking an unknown or
+	// already revoked token is still a no-op success.
+	var actorID uuid.UUID
+	if refreshToken, err := cfg.db.GetUserFromRefreshToken(r.Context(), token); err == nil {
+		actorID = refreshToken.UserID
+	}
+
 	err = cfg.db.UpdateRevoke(r.Context(), token)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error revoking token", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Error revoking token", err)
 		return
 	}
+	if actorID != uuid.Nil {
+		recordAudit(r.Context(), cfg.db, r, auditEntry{
+			Action: auditTokenRevoked,
+			ActorID: actorID,
+			TargetType: "user",
+			TargetID: actorID.String(),
+		})
+	}
 	respondWithJSON(w, 204, nil)
 }
 
@@ -168,25 +237,34 @@ func(cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
 		return
 	}
 
-	UserID, err := auth.ValidateJWT(token, cfg.secret)
+	UserID, err := validateJWT(r.Context(), token, cfg.secret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
+		return
+	}
+	if !cfg.requireNotSuspended(w, r, UserID) {
 		return
 	}
 	
 	params, err := getEmailPassword(r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		return
+	}
+
+	hashedPassword, err := hashPassword(r.Context(), params.Password)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Error hashing password", err)
 		return
 	}
 
-	hashedPassword, err := auth.HashPassword(params.Password)
+	previous, err := cfg.db.GetUserByID(r.Context(), UserID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error hashing password", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
 		return
 	}
 
@@ -198,9 +276,22 @@ func(cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
 
 	editedUser, err := cfg.db.UpdateUser(r.Context(), updateUserParams)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't update user", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update user", err)
 		return
 	}
+
+	updateMetadata := map[string]any{"password_changed": true}
+	if previous.Email != editedUser.Email {
+		updateMetadata["old_email"] = previous.Email
+		updateMetadata["new_email"] = editedUser.Email
+	}
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditUserUpdated,
+		ActorID: UserID,
+		TargetType: "user",
+		TargetID: UserID.String(),
+		Metadata: updateMetadata,
+	})
 	
 	respondWithJSON(w, http.StatusOK, User{
 		ID: editedUser.ID,
@@ -208,5 +299,6 @@ func(cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
 		UpdatedAt: editedUser.UpdatedAt,
 		Email: editedUser.Email,
 		IsChirpyRed: editedUser.IsChirpyRed,
+		Role: editedUser.Role,
 	})
 }
\ No newline at end of file
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+type AuditEvent struct {
+	ID			int64			`json:"id"`
+	CreatedAt	time.Time		`json:"created_at"`
+	Action		string			`json:"action"`
+	ActorID		*uuid.UUID		`json:"actor_id"`
+	TargetType	string			`json:"target_type"`
+	TargetID	string			`json:"target_id"`
+	IP			string			`json:"ip"`
+	UserAgent	string			`json:"user_agent"`
+	Metadata	json.RawMessage	`json:"metadata"`
+}
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit = 10000
+)
+
+// handlerListAuditEvents serves GET /admin/audit. Results can be filtered by
+// actor_id, action, target_id, since and until (RFC 3339), and are returned as
+// JSON, JSONL or CSV depending on format.
+func(cfg *apiConfig) handlerListAuditEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := database.ListAuditEventsParams{Limit: defaultAuditLimit}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditLimit {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		params.Limit = int32(limit)
+	}
+	if v := query.Get("actor_id"); v != "" {
+		actorID, err := uuid.Parse(v)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid actor_id", err)
+			return
+		}
+		params.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+	}
+	if v := query.Get("action"); v != "" {
+		params.Action = sql.NullString{String: v, Valid: true}
+	}
+	if v := query.Get("target_id"); v != "" {
+		params.TargetID = sql.NullString{String: v, Valid: true}
+	}
+	for name, dest := range map[string]*sql.NullTime{"since": &params.Since, "until": &params.Until} {
+		if v := query.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondWithError(w, r, http.StatusBadRequest, "Invalid "+name, err)
+				return
+			}
+			*dest = sql.NullTime{Time: t.UTC(), Valid: true}
+		}
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "jsonl" && format != "csv" {
+		respondWithError(w, r, http.StatusBadRequest, "format must be json, jsonl or csv", nil)
+		return
+	}
+
+	rows, err := cfg.db.ListAuditEvents(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not list audit events", err)
+		return
+	}
+
+	events := []AuditEvent{}
+	for _, row := range rows {
+		event := AuditEvent{
+			ID: row.ID,
+			CreatedAt: row.CreatedAt,
+			Action: row.Action,
+			TargetType: row.TargetType,
+			TargetID: row.TargetID,
+			IP: row.Ip,
+			UserAgent: row.UserAgent,
+			Metadata: row.Metadata,
+		}
+		if row.ActorID.Valid {
+			event.ActorID = &row.ActorID.UUID
+		}
+		events = append(events, event)
+	}
+
+	switch format {
+	case "json":
+		respondWithJSON(w, http.StatusOK, events)
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		w.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(w)
+		for _, event := range events {
+			encoder.Encode(event)
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+		w.WriteHeader(http.StatusOK)
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"id", "created_at", "action", "actor_id", "target_type", "target_id", "ip", "user_agent", "metadata"})
+		for _, event := range events {
+			actorID := ""
+			if event.ActorID != nil {
+				actorID = event.ActorID.String()
+			}
+			writer.Write([]string{
+				strconv.FormatInt(event.ID, 10),
+				event.CreatedAt.Format(time.RFC3339),
+				event.Action,
+				actorID,
+				event.TargetType,
+				event.TargetID,
+				event.IP,
+				event.UserAgent,
+				string(event.Metadata),
+			})
+		}
+		writer.Flush()
+	}
+}
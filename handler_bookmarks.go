@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+const (
+	maxBookmarkCollections = 50
+	maxCollectionNameLength = 50
+)
+
+type BookmarkCollection struct {
+	ID				uuid.UUID	`json:"id"`
+	CreatedAt		time.Time	`json:"created_at"`
+	UpdatedAt		time.Time	`json:"updated_at"`
+	Name			string		`json:"name"`
+	BookmarkCount	int64		`json:"bookmark_count"`
+}
+
+type Bookmark struct {
+	Chirp			Chirp		`json:"chirp"`
+	CollectionID	*uuid.UUID	`json:"collection_id"`
+	BookmarkedAt	time.Time	`json:"bookmarked_at"`
+	// Cursor is passed as before to list the bookmarks after this one.
+	Cursor			string		`json:"cursor,omitempty"`
+}
+
+// bookmarkCursor encodes a bookmark's place in the list. It carries the
+// position itself rather than naming a bookmark, so it keeps working if
+// that bookmark is removed.
+func bookmarkCursor(bookmarkedAt time.Time, chirpID uuid.UUID) string {
+	raw := strconv.FormatInt(bookmarkedAt.UnixMicro(), 10) + ":" + chirpID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func parseBookmarkCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	micros, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	unixMicro, err := strconv.ParseInt(micros, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	chirpID, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	return time.UnixMicro(unixMicro).UTC(), chirpID, nil
+}
+
+// bookmarkedAmong returns which of chirpIDs the user has bookmarked.
+func(cfg *apiConfig) bookmarkedAmong(ctx context.Context, userID uuid.UUID, chirpIDs []uuid.UUID) (map[uuid.UUID]bool, error) {
+	bookmarked := map[uuid.UUID]bool{}
+	if len(chirpIDs) == 0 {
+		return bookmarked, nil
+	}
+	ids, err := cfg.db.GetBookmarkedChirpIDs(ctx, database.GetBookmarkedChirpIDsParams{
+		UserID: userID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		bookmarked[id] = true
+	}
+	return bookmarked, nil
+}
+
+func collectionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxCollectionNameLength {
+		return "", errors.New("Collection names must be 1 to 50 characters")
+	}
+	return name, nil
+}
+
+// ownsBookmarkCollection reports whether the collection with the given ID
+// exists and belongs to the user.
+func(cfg *apiConfig) ownsBookmarkCollection(ctx context.Context, userID, id uuid.UUID) (bool, error) {
+	collection, err := cfg.db.GetBookmarkCollection(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return collection.UserID == userID, nil
+}
+
+func(cfg *apiConfig) handlerCreateBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	user, _ := authUserFromContext(r.Context())
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	name, err := collectionName(params.Name)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	count, err := cfg.db.CountBookmarkCollections(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create collection", err)
+		return
+	}
+	if count >= maxBookmarkCollections {
+		respondWithError(w, r, http.StatusBadRequest, "You can have at most "+strconv.Itoa(maxBookmarkCollections)+" collections", nil)
+		return
+	}
+
+	collection, err := cfg.db.CreateBookmarkCollection(r.Context(), database.CreateBookmarkCollectionParams{
+		UserID: user.ID,
+		Name: name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusConflict, "You already have a collection with that name", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create collection", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, BookmarkCollection{
+		ID: collection.ID,
+		CreatedAt: collection.CreatedAt,
+		UpdatedAt: collection.UpdatedAt,
+		Name: collection.Name,
+	})
+}
+
+// handlerListBookmarkCollections returns the caller's collections by name,
+// with how many bookmarks are in each.
+func(cfg *apiConfig) handlerListBookmarkCollections(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	collections, err := cfg.db.ListBookmarkCollections(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list collections", err)
+		return
+	}
+
+	response := []BookmarkCollection{}
+	for _, collection := range collections {
+		response = append(response, BookmarkCollection{
+			ID: collection.ID,
+			CreatedAt: collection.CreatedAt,
+			UpdatedAt: collection.UpdatedAt,
+			Name: collection.Name,
+			BookmarkCount: collection.BookmarkCount,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func(cfg *apiConfig) handlerRenameBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	user, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "collection_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid collection ID", err)
+		return
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	name, err := collectionName(params.Name)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	ok, err := cfg.ownsBookmarkCollection(r.Context(), user.ID, id)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get collection", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, r, http.StatusNotFound, "Collection not found", nil)
+		return
+	}
+
+	collection, err := cfg.db.RenameBookmarkCollection(r.Context(), database.RenameBookmarkCollectionParams{
+		ID: id,
+		Name: name,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusConflict, "You already have a collection with that name", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't rename collection", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, BookmarkCollection{
+		ID: collection.ID,
+		CreatedAt: collection.CreatedAt,
+		UpdatedAt: collection.UpdatedAt,
+		Name: collection.Name,
+	})
+}
+
+// handlerDeleteBookmarkCollection deletes a collection. Its bookmarks are
+// kept, outside any collection.
+func(cfg *apiConfig) handlerDeleteBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "collection_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid collection ID", err)
+		return
+	}
+	ok, err := cfg.ownsBookmarkCollection(r.Context(), user.ID, id)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get collection", err)
+		return
+	}
+	if !ok {
+		respondWithError(w, r, http.StatusNotFound, "Collection not found", nil)
+		return
+	}
+	if err := cfg.db.DeleteBookmarkCollection(r.Context(), id); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete collection", err)
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// handlerAddBookmark bookmarks a chirp, optionally in one of the caller's
+// collections. Bookmarking a chirp again moves it to the given collection,
+// or out of any if none is given.
+func(cfg *apiConfig) handlerAddBookmark(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		CollectionID *uuid.UUID `json:"collection_id"`
+	}
+
+	user, _ := authUserFromContext(r.Context())
+
+	chirpID, err := pathUUID(r, "chirp_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+	// The body is optional.
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && chirp.HiddenAt.Valid) {
+		respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+	blocked, err := cfg.db.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{
+		UserID: user.ID,
+		TargetID: chirp.UserID,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
+		return
+	}
+
+	var collectionID uuid.NullUUID
+	if params.CollectionID != nil {
+		ok, err := cfg.ownsBookmarkCollection(r.Context(), user.ID, *params.CollectionID)
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't get collection", err)
+			return
+		}
+		if !ok {
+			respondWithError(w, r, http.StatusBadRequest, "Collection not found", nil)
+			return
+		}
+		collectionID = uuid.NullUUID{UUID: *params.CollectionID, Valid: true}
+	}
+
+	bookmark, err := cfg.db.UpsertBookmark(r.Context(), database.UpsertBookmarkParams{
+		UserID: user.ID,
+		ChirpID: chirp.ID,
+		CollectionID: collectionID,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't bookmark chirp", err)
+		return
+	}
+
+	response := []Chirp{{
+		ID: chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body: chirp.Body,
+		UserID: chirp.UserID,
+	}}
+	if err := cfg.attachChirpDetails(r.Context(), response, uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, Bookmark{
+		Chirp: response[0],
+		CollectionID: nullableUUID(bookmark.CollectionID),
+		BookmarkedAt: bookmark.CreatedAt,
+	})
+}
+
+func(cfg *apiConfig) handlerRemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	chirpID, err := pathUUID(r, "chirp_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+	deleted, err := cfg.db.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID: user.ID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't remove bookmark", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, http.StatusNotFound, "Bookmark not found", nil)
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// handlerListBookmarks returns the caller's bookmarks, most recent first.
+// Pass collection_id for one collection only, and the cursor of the last
+// bookmark received as before to get the next page. Chirps that have been
+// hidden, or whose authors are blocked either way, are left out.
+func(cfg *apiConfig) handlerListBookmarks(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	params := database.ListBookmarksParams{
+		UserID: user.ID,
+		Limit: 50,
+	}
+	if v := r.URL.Query().Get("collection_id"); v != "" {
+		collectionID, err := uuid.Parse(v)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid collection_id", err)
+			return
+		}
+		ok, err := cfg.ownsBookmarkCollection(r.Context(), user.ID, collectionID)
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't get collection", err)
+			return
+		}
+		if !ok {
+			respondWithError(w, r, http.StatusNotFound, "Collection not found", nil)
+			return
+		}
+		params.CollectionID = uuid.NullUUID{UUID: collectionID, Valid: true}
+	}
+	if v := r.URL.Query().Get("before"); v != "" {
+		bookmarkedAt, chirpID, err := parseBookmarkCursor(v)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid before", err)
+			return
+		}
+		params.BeforeCreatedAt = sql.NullTime{Time: bookmarkedAt, Valid: true}
+		params.BeforeChirpID = uuid.NullUUID{UUID: chirpID, Valid: true}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		params.Limit = int32(limit)
+	}
+
+	rows, err := cfg.db.ListBookmarks(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list bookmarks", err)
+		return
+	}
+
+	chirps := make([]Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, Chirp{
+			ID: row.ID,
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+			Body: row.Body,
+			UserID: row.UserID,
+		})
+	}
+	if err := cfg.attachChirpDetails(r.Context(), chirps, uuid.NullUUID{UUID: user.ID, Valid: true}); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list bookmarks", err)
+		return
+	}
+
+	response := []Bookmark{}
+	for i, row := range rows {
+		response = append(response, Bookmark{
+			Chirp: chirps[i],
+			CollectionID: nullableUUID(row.CollectionID),
+			BookmarkedAt: row.BookmarkedAt,
+			Cursor: bookmarkCursor(row.BookmarkedAt, row.ID),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
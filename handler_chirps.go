@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"sort"
 	"strings"
@@ -10,6 +12,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/ppllama/chirpy/internal/auth"
 	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/filter"
 )
 
 type Chirp struct {
@@ -18,11 +21,24 @@ type Chirp struct {
 		UpdatedAt time.Time	`json:"updated_at"`
 		Body      string	`json:"body"`
 		UserID    uuid.UUID	`json:"user_id"`
+		Poll      *Poll		`json:"poll,omitempty"`
+		// Bookmarked is only set when the request carries an access token.
+		Bookmarked *bool	`json:"bookmarked,omitempty"`
 	}
 
+const maxChirpLength = 140
+
+var (
+	errChirpTooLong = errors.New("Chirp is too long")
+	errChirpBlocked = errors.New("Chirp contains a blocked word")
+)
+
 func(cfg *apiConfig) handlerPostChirps(w http.ResponseWriter, r *http.Request) {
 	type parameters struct {
 		Body string `json:"body"`
+		// PublishAt, if set, schedules the chirp instead of posting it now.
+		PublishAt *time.Time `json:"publish_at"`
+		Poll *pollParams `json:"poll"`
 	}
 	type responseCleaned struct {
 		Cleaned_body string `json:"cleaned_body"`
@@ -30,13 +46,16 @@ func(cfg *apiConfig) handlerPostChirps(w http.ResponseWriter, r *http.Request) {
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
 		return
 	}
 
-	UserID, err := auth.ValidateJWT(token, cfg.secret)
+	UserID, err := validateJWT(r.Context(), token, cfg.secret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
+		return
+	}
+	if !cfg.requireNotSuspended(w, r, UserID) {
 		return
 	}
 
@@ -44,36 +63,142 @@ func(cfg *apiConfig) handlerPostChirps(w http.ResponseWriter, r *http.Request) {
 	params := parameters{}
 	err = decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(params.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
+	if params.PublishAt != nil {
+		if params.Poll != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Chirps with polls can't be scheduled", nil)
+			return
+		}
+		cfg.scheduleChirp(w, r, UserID, params.Body, *params.PublishAt)
 		return
 	}
 
-	cleaned := badWordReplace(params.Body)
-
-	chirpParams := database.CreateChirpParams{
-		Body: cleaned,
-		UserID: UserID,
+	filtered, err := cfg.checkChirp(params.Body)
+	for _, match := range filtered.Matches {
+		cfg.metrics.filterMatches.WithLabelValues(string(match.Action)).Inc()
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	var pollOptions []string
+	var pollDuration time.Duration
+	if params.Poll != nil {
+		pollOptions, pollDuration, err = cfg.checkPoll(*params.Poll)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
 	}
 
-	newChirp, err := cfg.db.CreateChirp(r.Context(), chirpParams)
+	var newChirp database.Chirp
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		newChirp, err = cfg.createChirp(r.Context(), q, UserID, filtered)
+		if err != nil || params.Poll == nil {
+			return err
+		}
+		return createPoll(r.Context(), q, newChirp.ID, pollOptions, pollDuration)
+	})
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Could not create Chirp", err)
+		respondWithError(w, r, http.StatusBadRequest, "Could not create Chirp", err)
 		return
 	}
+	cfg.metrics.chirpsCreated.Inc()
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
+	response := []Chirp{{
 		ID: newChirp.ID,
 		CreatedAt: newChirp.CreatedAt,
 		UpdatedAt: newChirp.UpdatedAt,
 		Body: newChirp.Body,
 		UserID: newChirp.UserID,
+	}}
+	err = cfg.attachChirpDetails(r.Context(), response, uuid.NullUUID{UUID: UserID, Valid: true})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get poll", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, response[0])
+}
+
+// checkChirp validates a chirp's body and runs it through the content
+// filter.
+func(cfg *apiConfig) checkChirp(body string) (filter.Result, error) {
+	if len(body) > maxChirpLength {
+		return filter.Result{}, errChirpTooLong
+	}
+	filtered := cfg.filter.Current().Apply(body)
+	if filtered.Rejected() {
+		return filtered, errChirpBlocked
+	}
+	return filtered, nil
+}
+
+// createChirp creates a chirp checked by checkChirp in q's transaction,
+// along with what goes with every new chirp: a report for moderators if the
+// content filter flagged it, and webhook events. Live events come from the
+// chirps trigger.
+func(cfg *apiConfig) createChirp(ctx context.Context, q *database.Queries, userID uuid.UUID, filtered filter.Result) (database.Chirp, error) {
+	newChirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+		Body: filtered.Text,
+		UserID: userID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if filtered.Flagged() {
+		_, err = q.CreateReport(ctx, database.CreateReportParams{
+			ReportedUserID: newChirp.UserID,
+			ChirpID: uuid.NullUUID{UUID: newChirp.ID, Valid: true},
+			Reason: flaggedReason(filtered),
+		})
+		if err != nil {
+			return database.Chirp{}, err
+		}
+	}
+	err = enqueueWebhook(ctx, q, webhookEvent{
+		Type: webhookChirpCreated,
+		SubjectUserID: newChirp.UserID,
+		Data: Chirp{
+			ID: newChirp.ID,
+			CreatedAt: newChirp.CreatedAt,
+			UpdatedAt: newChirp.UpdatedAt,
+			Body: newChirp.Body,
+			UserID: newChirp.UserID,
+		},
 	})
+	return newChirp, err
+}
+
+// attachChirpDetails fills in what a chirp's JSON shows beyond the chirp
+// itself: its poll, if it has one, and whether viewer has bookmarked it.
+func(cfg *apiConfig) attachChirpDetails(ctx context.Context, chirps []Chirp, viewerID uuid.NullUUID) error {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.ID)
+	}
+	polls, err := cfg.pollsFor(ctx, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range chirps {
+		chirps[i].Poll = polls[chirps[i].ID]
+	}
+	if !viewerID.Valid {
+		return nil
+	}
+
+	bookmarked, err := cfg.bookmarkedAmong(ctx, viewerID.UUID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range chirps {
+		isBookmarked := bookmarked[chirps[i].ID]
+		chirps[i].Bookmarked = &isBookmarked
+	}
+	return nil
 }
 
 func(cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
@@ -84,17 +209,25 @@ func(cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 	var chirps []database.Chirp
 	var err error
 
+	// What a user sees depends on who they are: limited users still see
+	// their own chirps, and blocks and mutes hide others'. Anonymous
+	// requests are fine.
+	viewerID := cfg.optionalViewer(r)
+
 	if authorIDStr == "" {
-		chirps, err = cfg.db.GetAllChirps(r.Context())
+		chirps, err = cfg.db.GetAllChirps(r.Context(), viewerID)
 	} else {
 		authorID, err := uuid.Parse(authorIDStr)
 		if err != nil {
-			chirps, err = cfg.db.GetAllChirps(r.Context())
+			chirps, err = cfg.db.GetAllChirps(r.Context(), viewerID)
 		}
-		chirps, err = cfg.db.GetAllChirpsByUser(r.Context(), authorID)
+		chirps, err = cfg.db.GetAllChirpsByUser(r.Context(), database.GetAllChirpsByUserParams{
+			UserID: authorID,
+			ViewerID: viewerID,
+		})
 	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error getting all chirps", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Error getting all chirps", err)
 		return
 	}
 
@@ -115,6 +248,10 @@ func(cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 			UserID: chirp.UserID,
 		})
 	}
+	if err := cfg.attachChirpDetails(r.Context(), allChirps, viewerID); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Error getting polls", err)
+		return
+	}
 
 	respondWithJSON(w, http.StatusOK, allChirps)
 }
@@ -122,98 +259,127 @@ func(cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
 func(cfg *apiConfig) handlerChirp(w http.ResponseWriter, r *http.Request) {
 	requestID := r.PathValue("chirp_id")
 	if requestID == "" {
-		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
 		return
 	}
 	id, err := uuid.Parse(requestID)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
 		return
 	}
 	responseChirp, err := cfg.db.GetChirp(r.Context(), id)
 	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
-			respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+			respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
 			return
 		}
-		respondWithError(w, http.StatusInternalServerError, "Could not get chirp", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Could not get chirp", err)
+		return
+	}
+	if responseChirp.HiddenAt.Valid {
+		respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
 		return
 	}
+	viewerID := cfg.optionalViewer(r)
+	if viewerID.Valid {
+		blocked, err := cfg.db.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{
+			UserID: viewerID.UUID,
+			TargetID: responseChirp.UserID,
+		})
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Could not get chirp", err)
+			return
+		}
+		if blocked {
+			respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
+			return
+		}
+	}
 
-	respondWithJSON(w, http.StatusOK, Chirp{
+	response := []Chirp{{
 		ID: responseChirp.ID,
 		CreatedAt: responseChirp.CreatedAt,
 		UpdatedAt: responseChirp.UpdatedAt,
 		Body: responseChirp.Body,
 		UserID: responseChirp.UserID,
-	})
+	}}
+	if err := cfg.attachChirpDetails(r.Context(), response, viewerID); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not get poll", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response[0])
 }
 
 func(cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
 
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
 		return
 	}
 
-	UserID, err := auth.ValidateJWT(token, cfg.secret)
+	UserID, err := validateJWT(r.Context(), token, cfg.secret)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Unauthorised", err)
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
+		return
+	}
+	if !cfg.requireNotSuspended(w, r, UserID) {
 		return
 	}
 
 	requestID := r.PathValue("chirp_id")
 	if requestID == "" {
-		respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+		respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
 		return
 	}
 	id, err := uuid.Parse(requestID)
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "Invalid chirp ID", err)
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
 		return
 	}
 
 	responseChirp, err := cfg.db.GetChirp(r.Context(), id)
 	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
-			respondWithError(w, http.StatusNotFound, "Chirp not found", nil)
+			respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
 			return
 		}
-		respondWithError(w, http.StatusInternalServerError, "Could not get chirp", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Could not get chirp", err)
 		return
 	}
 
 	if responseChirp.UserID != UserID {
-		respondWithError(w, http.StatusForbidden, "Forbidden", nil)
+		respondWithError(w, r, http.StatusForbidden, "Forbidden", nil)
 		return
 	}
 
-	err = cfg.db.DeleteChirp(r.Context(), responseChirp.ID)
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		if err := q.DeleteChirp(r.Context(), responseChirp.ID); err != nil {
+			return err
+		}
+		return enqueueWebhook(r.Context(), q, chirpDeletedEvent(responseChirp.ID, responseChirp.UserID, "deleted"))
+	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error deleting chirp", err)
+		respondWithError(w, r, http.StatusInternalServerError, "Error deleting chirp", err)
 		return
 	}
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditChirpDeleted,
+		ActorID: UserID,
+		TargetType: "chirp",
+		TargetID: responseChirp.ID.String(),
+		Metadata: map[string]any{"author_id": responseChirp.UserID, "body": responseChirp.Body},
+	})
 
 	respondWithJSON(w, http.StatusNoContent, nil)
 }
 
-func badWordReplace(input string) string {
-
-	badWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert": {},
-		"fornax": {},
-	}
-
-	splitInput := strings.Split(input, " ")
-	
-	for i, word := range(splitInput) {
-		if _, ok := badWords[strings.ToLower(word)]; ok {
-			splitInput[i] = "****"
+func flaggedReason(result filter.Result) string {
+	var words []string
+	for _, match := range result.Matches {
+		if match.Action == filter.ActionFlag {
+			words = append(words, match.Word)
 		}
 	}
-
-
-	return strings.Join(splitInput, " ")
-}
\ No newline at end of file
+	return "Content filter matched: " + strings.Join(words, ", ")
+}
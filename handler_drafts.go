@@ -0,0 +1,266 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/filter"
+)
+
+const (
+	maxDrafts = 100
+	// Drafts may run over the chirp limit while being worked on, but not
+	// without bound.
+	maxDraftLength = 10000
+)
+
+var errDraftNotFound = errors.New("draft not found")
+
+// DraftWarning is a problem that would stop a draft being published as it
+// stands, or change it when it is.
+type DraftWarning struct {
+	Code	string	`json:"code"`
+	Message	string	`json:"message"`
+}
+
+type Draft struct {
+	ID			uuid.UUID		`json:"id"`
+	CreatedAt	time.Time		`json:"created_at"`
+	UpdatedAt	time.Time		`json:"updated_at"`
+	Body		string			`json:"body"`
+	Warnings	[]DraftWarning	`json:"warnings"`
+}
+
+// draftFromDB returns the draft with the warnings handlerPostChirps would
+// give for its body today.
+func(cfg *apiConfig) draftFromDB(draft database.Draft) Draft {
+	warnings := []DraftWarning{}
+	filtered, err := cfg.checkChirp(draft.Body)
+	switch {
+	case errors.Is(err, errChirpTooLong):
+		warnings = append(warnings, DraftWarning{
+			Code: "too_long",
+			Message: "Chirp is too long: " + strconv.Itoa(len(draft.Body)) + " of " + strconv.Itoa(maxChirpLength) + " characters",
+		})
+	case errors.Is(err, errChirpBlocked):
+		warnings = append(warnings, DraftWarning{Code: "blocked_word", Message: err.Error()})
+	case hasMaskedWords(filtered):
+		warnings = append(warnings, DraftWarning{Code: "masked_words", Message: "Some words will be replaced with ****"})
+	}
+	return Draft{
+		ID: draft.ID,
+		CreatedAt: draft.CreatedAt,
+		UpdatedAt: draft.UpdatedAt,
+		Body: draft.Body,
+		Warnings: warnings,
+	}
+}
+
+func hasMaskedWords(result filter.Result) bool {
+	for _, match := range result.Matches {
+		if match.Action == filter.ActionMask {
+			return true
+		}
+	}
+	return false
+}
+
+// ownedDraft loads the draft named in the path, responding 404 if it doesn't
+// exist or belongs to someone else.
+func(cfg *apiConfig) ownedDraft(w http.ResponseWriter, r *http.Request) (database.Draft, bool) {
+	user, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "draft_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid draft ID", err)
+		return database.Draft{}, false
+	}
+	draft, err := cfg.db.GetDraft(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && draft.UserID != user.ID) {
+		respondWithError(w, r, http.StatusNotFound, "Draft not found", nil)
+		return database.Draft{}, false
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get draft", err)
+		return database.Draft{}, false
+	}
+	return draft, true
+}
+
+func decodeDraftBody(w http.ResponseWriter, r *http.Request) (string, bool) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return "", false
+	}
+	if len(params.Body) > maxDraftLength {
+		respondWithError(w, r, http.StatusBadRequest, "Draft is too long", nil)
+		return "", false
+	}
+	return params.Body, true
+}
+
+// handlerCreateDraft saves a new draft. It is saved even if it couldn't be
+// posted as it stands; the problems are returned as warnings.
+func(cfg *apiConfig) handlerCreateDraft(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	body, ok := decodeDraftBody(w, r)
+	if !ok {
+		return
+	}
+
+	count, err := cfg.db.CountDrafts(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save draft", err)
+		return
+	}
+	if count >= maxDrafts {
+		respondWithError(w, r, http.StatusBadRequest, "You can have at most "+strconv.Itoa(maxDrafts)+" drafts", nil)
+		return
+	}
+
+	draft, err := cfg.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID: user.ID,
+		Body: body,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save draft", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, cfg.draftFromDB(draft))
+}
+
+// handlerListDrafts returns the caller's drafts, most recently edited first.
+func(cfg *apiConfig) handlerListDrafts(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	drafts, err := cfg.db.ListDrafts(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list drafts", err)
+		return
+	}
+
+	response := []Draft{}
+	for _, draft := range drafts {
+		response = append(response, cfg.draftFromDB(draft))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func(cfg *apiConfig) handlerGetDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := cfg.ownedDraft(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, cfg.draftFromDB(draft))
+}
+
+func(cfg *apiConfig) handlerUpdateDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := cfg.ownedDraft(w, r)
+	if !ok {
+		return
+	}
+	body, ok := decodeDraftBody(w, r)
+	if !ok {
+		return
+	}
+
+	draft, err := cfg.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		ID: draft.ID,
+		Body: body,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Draft not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't save draft", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, cfg.draftFromDB(draft))
+}
+
+func(cfg *apiConfig) handlerDeleteDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := cfg.ownedDraft(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.db.DeleteDraft(r.Context(), draft.ID); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete draft", err)
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// handlerPublishDraft posts a draft as a chirp and deletes the draft, in one
+// transaction, so a draft becomes exactly one chirp. Unlike saving, anything
+// that would stop the chirp being posted is an error here.
+func(cfg *apiConfig) handlerPublishDraft(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "draft_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid draft ID", err)
+		return
+	}
+
+	var newChirp database.Chirp
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		draft, err := q.LockDraft(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && draft.UserID != user.ID) {
+			return errDraftNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		filtered, err := cfg.checkChirp(draft.Body)
+		for _, match := range filtered.Matches {
+			cfg.metrics.filterMatches.WithLabelValues(string(match.Action)).Inc()
+		}
+		if err != nil {
+			return err
+		}
+		newChirp, err = cfg.createChirp(r.Context(), q, user.ID, filtered)
+		if err != nil {
+			return err
+		}
+		return q.DeleteDraft(r.Context(), draft.ID)
+	})
+	switch {
+	case errors.Is(err, errDraftNotFound):
+		respondWithError(w, r, http.StatusNotFound, "Draft not found", nil)
+		return
+	case errors.Is(err, errChirpTooLong), errors.Is(err, errChirpBlocked):
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	case err != nil:
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't publish draft", err)
+		return
+	}
+	cfg.metrics.chirpsCreated.Inc()
+
+	response := []Chirp{{
+		ID: newChirp.ID,
+		CreatedAt: newChirp.CreatedAt,
+		UpdatedAt: newChirp.UpdatedAt,
+		Body: newChirp.Body,
+		UserID: newChirp.UserID,
+	}}
+	err = cfg.attachChirpDetails(r.Context(), response, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get chirp details", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, response[0])
+}
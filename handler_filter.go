@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/filter"
+)
+
+type FilterRule struct {
+	Word		string		`json:"word"`
+	Action		string		`json:"action"`
+	CreatedAt	time.Time	`json:"created_at"`
+	UpdatedAt	time.Time	`json:"updated_at"`
+	CreatedBy	*uuid.UUID	`json:"created_by"`
+}
+
+func filterRuleFromDB(rule database.FilterRule) FilterRule {
+	response := FilterRule{
+		Word: rule.Word,
+		Action: rule.Action,
+		CreatedAt: rule.CreatedAt,
+		UpdatedAt: rule.UpdatedAt,
+	}
+	if rule.CreatedBy.Valid {
+		response.CreatedBy = &rule.CreatedBy.UUID
+	}
+	return response
+}
+
+// reloadFilter applies a rule change straight away on this instance. Other
+// instances pick it up on their next periodic reload.
+func(cfg *apiConfig) reloadFilter(r *http.Request) {
+	if err := cfg.filter.Reload(r.Context()); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to reload content filter", "error", err)
+	}
+}
+
+// handlerListFilterRules lists the rules stored in the database. Rules from
+// FILTER_FILE are managed on disk and aren't included.
+func(cfg *apiConfig) handlerListFilterRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := cfg.db.ListFilterRules(r.Context())
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not list filter rules", err)
+		return
+	}
+
+	response := []FilterRule{}
+	for _, rule := range rules {
+		response = append(response, filterRuleFromDB(rule))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func(cfg *apiConfig) handlerPutFilterRule(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action string `json:"action"`
+	}
+
+	actor, _ := authUserFromContext(r.Context())
+
+	word, err := filter.NormalizeWord(r.PathValue("word"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Rules must be a single word", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if !filter.Action(params.Action).Valid() {
+		respondWithError(w, r, http.StatusBadRequest, "action must be mask, reject or flag", nil)
+		return
+	}
+
+	rule, err := cfg.db.UpsertFilterRule(r.Context(), database.UpsertFilterRuleParams{
+		Word: word,
+		Action: params.Action,
+		CreatedBy: uuid.NullUUID{UUID: actor.ID, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not save filter rule", err)
+		return
+	}
+	cfg.reloadFilter(r)
+
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditFilterRuleSet,
+		ActorID: actor.ID,
+		TargetType: "filter_rule",
+		TargetID: rule.Word,
+		Metadata: map[string]any{"action": rule.Action},
+	})
+	respondWithJSON(w, http.StatusOK, filterRuleFromDB(rule))
+}
+
+func(cfg *apiConfig) handlerDeleteFilterRule(w http.ResponseWriter, r *http.Request) {
+	actor, _ := authUserFromContext(r.Context())
+
+	word, err := filter.NormalizeWord(r.PathValue("word"))
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Rules must be a single word", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteFilterRule(r.Context(), word)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not delete filter rule", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, http.StatusNotFound, "Filter rule not found", nil)
+		return
+	}
+	cfg.reloadFilter(r)
+
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditFilterRuleDeleted,
+		ActorID: actor.ID,
+		TargetType: "filter_rule",
+		TargetID: word,
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// handlerReloadFilter rereads the database and FILTER_FILE, e.g. after the
+// file has been edited.
+func(cfg *apiConfig) handlerReloadFilter(w http.ResponseWriter, r *http.Request) {
+	if err := cfg.filter.Reload(r.Context()); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not reload filter", err)
+		return
+	}
+
+	type response struct {
+		Words int `json:"words"`
+	}
+	respondWithJSON(w, http.StatusOK, response{Words: cfg.filter.Current().Len()})
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+var jobStatuses = []string{"pending", "running", "succeeded", "dead"}
+
+type Job struct {
+	ID			uuid.UUID		`json:"id"`
+	CreatedAt	time.Time		`json:"created_at"`
+	UpdatedAt	time.Time		`json:"updated_at"`
+	Kind		string			`json:"kind"`
+	Payload		json.RawMessage	`json:"payload"`
+	Status		string			`json:"status"`
+	Attempts	int32			`json:"attempts"`
+	MaxAttempts	int32			`json:"max_attempts"`
+	// RunAt is when a pending job is due, or when a running job's lease
+	// runs out.
+	RunAt		*time.Time		`json:"run_at,omitempty"`
+	LastError	string			`json:"last_error,omitempty"`
+	FinishedAt	*time.Time		`json:"finished_at,omitempty"`
+}
+
+func jobFromDB(job database.Job) Job {
+	response := Job{
+		ID: job.ID,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+		Kind: job.Kind,
+		Payload: job.Payload,
+		Status: job.Status,
+		Attempts: job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		LastError: job.LastError.String,
+	}
+	if job.Status == "pending" || job.Status == "running" {
+		response.RunAt = &job.RunAt
+	}
+	if job.FinishedAt.Valid {
+		response.FinishedAt = &job.FinishedAt.Time
+	}
+	return response
+}
+
+// handlerListJobs returns the most recently created jobs, optionally only
+// those with the given status or kind.
+func(cfg *apiConfig) handlerListJobs(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	params := database.ListJobsParams{Limit: 100}
+	if status := query.Get("status"); status != "" {
+		if !slices.Contains(jobStatuses, status) {
+			respondWithError(w, r, http.StatusBadRequest, "status must be pending, running, succeeded or dead", nil)
+			return
+		}
+		params.Status = sql.NullString{String: status, Valid: true}
+	}
+	if kind := query.Get("kind"); kind != "" {
+		params.Kind = sql.NullString{String: kind, Valid: true}
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 1000 {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		params.Limit = int32(limit)
+	}
+
+	jobs, err := cfg.db.ListJobs(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list jobs", err)
+		return
+	}
+
+	response := []Job{}
+	for _, job := range jobs {
+		response = append(response, jobFromDB(job))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerJobStats returns the number of jobs of each kind in each status.
+func(cfg *apiConfig) handlerJobStats(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.db.CountJobsByKindAndStatus(r.Context())
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't count jobs", err)
+		return
+	}
+
+	response := map[string]map[string]int64{}
+	for _, row := range rows {
+		if response[row.Kind] == nil {
+			response[row.Kind] = map[string]int64{}
+		}
+		response[row.Kind][row.Status] = row.Count
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func(cfg *apiConfig) handlerGetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r, "job_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid job ID", err)
+		return
+	}
+	job, err := cfg.db.GetJob(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Job not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get job", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, jobFromDB(job))
+}
+
+// handlerRetryJob queues a dead job to run again with a fresh set of
+// attempts.
+func(cfg *apiConfig) handlerRetryJob(w http.ResponseWriter, r *http.Request) {
+	admin, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "job_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid job ID", err)
+		return
+	}
+	job, err := cfg.db.RequeueDeadJob(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := cfg.db.GetJob(r.Context(), id); errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "Job not found", nil)
+			return
+		}
+		respondWithError(w, r, http.StatusConflict, "Only dead jobs can be retried", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't retry job", err)
+		return
+	}
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditJobRetried,
+		ActorID: admin.ID,
+		TargetType: "job",
+		TargetID: job.ID.String(),
+		Metadata: map[string]any{"kind": job.Kind},
+	})
+	respondWithJSON(w, http.StatusOK, jobFromDB(job))
+}
+
+// handlerDeleteJob deletes a succeeded or dead job.
+func(cfg *apiConfig) handlerDeleteJob(w http.ResponseWriter, r *http.Request) {
+	admin, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "job_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid job ID", err)
+		return
+	}
+	job, err := cfg.db.GetJob(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Job not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get job", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteJob(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete job", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, r, http.StatusConflict, "Only finished jobs can be deleted", nil)
+		return
+	}
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditJobDeleted,
+		ActorID: admin.ID,
+		TargetType: "job",
+		TargetID: job.ID.String(),
+		Metadata: map[string]any{"kind": job.Kind, "status": job.Status},
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/jobs"
+)
+
+const (
+	maxConversationMembers = 10
+	maxMessageLength = 2000
+)
+
+type Conversation struct {
+	ID			uuid.UUID	`json:"id"`
+	CreatedAt	time.Time	`json:"created_at"`
+	UpdatedAt	time.Time	`json:"updated_at"`
+	MemberIDs	[]uuid.UUID	`json:"member_ids"`
+	UnreadCount	int64		`json:"unread_count"`
+}
+
+type Message struct {
+	ID				uuid.UUID	`json:"id"`
+	CreatedAt		time.Time	`json:"created_at"`
+	ConversationID	uuid.UUID	`json:"conversation_id"`
+	SenderID		*uuid.UUID	`json:"sender_id"`
+	Body			string		`json:"body"`
+}
+
+func messageFromDB(message database.Message) Message {
+	response := Message{
+		ID: message.ID,
+		CreatedAt: message.CreatedAt,
+		ConversationID: message.ConversationID,
+		Body: message.Body,
+	}
+	if message.SenderID.Valid {
+		response.SenderID = &message.SenderID.UUID
+	}
+	return response
+}
+
+var errNotMember = errors.New("not a conversation member")
+
+// conversationMembers returns the members of a conversation, or errNotMember
+// if userID isn't one of them. Callers answer errNotMember with a 404 so
+// conversations can't be probed for.
+func(cfg *apiConfig) conversationMembers(ctx context.Context, conversationID, userID uuid.UUID) ([]uuid.UUID, error) {
+	members, err := cfg.db.GetConversationMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(members, userID) {
+		return nil, errNotMember
+	}
+	return members, nil
+}
+
+func respondConversationError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errNotMember) {
+		respondWithError(w, r, http.StatusNotFound, "Conversation not found", nil)
+		return
+	}
+	respondWithError(w, r, http.StatusInternalServerError, "Couldn't get conversation", err)
+}
+
+// handlerCreateConversation starts a conversation between the caller and
+// user_ids. Starting a one-to-one conversation that already exists returns
+// the existing one. Nobody can be added across a block.
+func(cfg *apiConfig) handlerCreateConversation(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		UserIDs []uuid.UUID `json:"user_ids"`
+	}
+
+	user, _ := authUserFromContext(r.Context())
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	others := []uuid.UUID{}
+	for _, id := range params.UserIDs {
+		if id != user.ID && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		respondWithError(w, r, http.StatusBadRequest, "user_ids must name at least one other user", nil)
+		return
+	}
+	if len(others)+1 > maxConversationMembers {
+		respondWithError(w, r, http.StatusBadRequest, "Conversations can have at most "+strconv.Itoa(maxConversationMembers)+" members", nil)
+		return
+	}
+
+	for _, id := range others {
+		if _, err := cfg.db.GetUserByID(r.Context(), id); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, r, http.StatusNotFound, "User not found", nil)
+				return
+			}
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+			return
+		}
+	}
+
+	blocked, err := cfg.db.IsBlockedWithAny(r.Context(), database.IsBlockedWithAnyParams{
+		UserID: user.ID,
+		OtherIds: others,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't check blocks", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, r, http.StatusForbidden, "You can't message this user", nil)
+		return
+	}
+
+	if len(others) == 1 {
+		existing, err := cfg.db.FindDirectConversation(r.Context(), database.FindDirectConversationParams{
+			UserA: user.ID,
+			UserB: others[0],
+		})
+		if err == nil {
+			respondWithJSON(w, http.StatusOK, Conversation{
+				ID: existing.ID,
+				CreatedAt: existing.CreatedAt,
+				UpdatedAt: existing.UpdatedAt,
+				MemberIDs: []uuid.UUID{user.ID, others[0]},
+			})
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't get conversation", err)
+			return
+		}
+	}
+
+	members := append([]uuid.UUID{user.ID}, others...)
+	var conversation database.Conversation
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		conversation, err = q.CreateConversation(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+		if err != nil {
+			return err
+		}
+		for _, id := range members {
+			if err := q.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+				ConversationID: conversation.ID,
+				UserID: id,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create conversation", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, Conversation{
+		ID: conversation.ID,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+		MemberIDs: members,
+	})
+}
+
+// handlerListConversations lists the caller's conversations, most recently
+// active first, with how many messages in each they haven't read.
+func(cfg *apiConfig) handlerListConversations(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	conversations, err := cfg.db.ListConversationsForUser(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list conversations", err)
+		return
+	}
+
+	response := []Conversation{}
+	for _, conversation := range conversations {
+		response = append(response, Conversation{
+			ID: conversation.ID,
+			CreatedAt: conversation.CreatedAt,
+			UpdatedAt: conversation.UpdatedAt,
+			MemberIDs: conversation.MemberIds,
+			UnreadCount: conversation.UnreadCount,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerSendMessage posts to a conversation the caller belongs to. In a
+// one-to-one conversation a block either way stops new messages; in a group,
+// messages from blocked users are hidden from the blocker instead.
+func(cfg *apiConfig) handlerSendMessage(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	user, _ := authUserFromContext(r.Context())
+
+	conversationID, err := pathUUID(r, "conversation_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		respondWithError(w, r, http.StatusBadRequest, "Message body is required", nil)
+		return
+	}
+	if len(params.Body) > maxMessageLength {
+		respondWithError(w, r, http.StatusBadRequest, "Message is too long", nil)
+		return
+	}
+
+	members, err := cfg.conversationMembers(r.Context(), conversationID, user.ID)
+	if err != nil {
+		respondConversationError(w, r, err)
+		return
+	}
+
+	if len(members) == 2 {
+		other := members[0]
+		if other == user.ID {
+			other = members[1]
+		}
+		blocked, err := cfg.db.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{
+			UserID: user.ID,
+			TargetID: other,
+		})
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't check blocks", err)
+			return
+		}
+		if blocked {
+			respondWithError(w, r, http.StatusForbidden, "You can't message this user", nil)
+			return
+		}
+	}
+
+	var message database.Message
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		message, err = q.CreateMessage(r.Context(), database.CreateMessageParams{
+			ConversationID: conversationID,
+			SenderID: uuid.NullUUID{UUID: user.ID, Valid: true},
+			Body: params.Body,
+		})
+		if err != nil {
+			return err
+		}
+		if err := q.TouchConversation(r.Context(), conversationID); err != nil {
+			return err
+		}
+		return enqueueJob(r.Context(), q, jobNotifyMessage, notifyMessageArgs{MessageID: message.ID}, 0)
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't send message", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, messageFromDB(message))
+}
+
+type notifyMessageArgs struct {
+	MessageID uuid.UUID `json:"message_id"`
+}
+
+var jobNotifyMessage = jobs.Kind[notifyMessageArgs]{Name: "notify.message"}
+
+// notifyMessage notifies the other members of a conversation of a new
+// message. It runs as a job so that sending doesn't wait on a notification
+// per member.
+func(cfg *apiConfig) notifyMessage(ctx context.Context, job jobs.Job, args notifyMessageArgs) error {
+	message, err := cfg.db.GetMessage(ctx, args.MessageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	members, err := cfg.db.GetConversationMembers(ctx, message.ConversationID)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if member == message.SenderID.UUID {
+			continue
+		}
+		notify(ctx, cfg.db, notification{
+			UserID: member,
+			Type: notificationMessage,
+			ActorID: message.SenderID.UUID,
+			SubjectType: "conversation",
+			SubjectID: message.ConversationID.String(),
+		})
+	}
+	return nil
+}
+
+// handlerListMessages returns a page of messages, newest first. Pass the ID
+// of the last message received as before to get the next page.
+func(cfg *apiConfig) handlerListMessages(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	conversationID, err := pathUUID(r, "conversation_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	params := database.ListMessagesParams{
+		ConversationID: conversationID,
+		Limit: 50,
+		ViewerID: user.ID,
+	}
+	if v := r.URL.Query().Get("before"); v != "" {
+		before, err := uuid.Parse(v)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid before", err)
+			return
+		}
+		params.Before = uuid.NullUUID{UUID: before, Valid: true}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		params.Limit = int32(limit)
+	}
+
+	if _, err := cfg.conversationMembers(r.Context(), conversationID, user.ID); err != nil {
+		respondConversationError(w, r, err)
+		return
+	}
+
+	messages, err := cfg.db.ListMessages(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list messages", err)
+		return
+	}
+
+	response := []Message{}
+	for _, message := range messages {
+		response = append(response, messageFromDB(message))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func(cfg *apiConfig) handlerMarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	conversationID, err := pathUUID(r, "conversation_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid conversation ID", err)
+		return
+	}
+
+	updated, err := cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't mark conversation read", err)
+		return
+	}
+	if updated == 0 {
+		respondConversationError(w, r, errNotMember)
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func(cfg *apiConfig) handlerUnreadMessages(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	count, err := cfg.db.CountUnreadMessages(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't count unread messages", err)
+		return
+	}
+
+	type response struct {
+		UnreadCount int64 `json:"unread_count"`
+	}
+	respondWithJSON(w, http.StatusOK, response{UnreadCount: count})
+}
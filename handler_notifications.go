@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+// Notification is a group of notifications about the same subject. Its ID is
+// the group key, which is what mark-read takes.
+type Notification struct {
+	ID			string		`json:"id"`
+	Type		string		`json:"type"`
+	SubjectType	string		`json:"subject_type"`
+	SubjectID	string		`json:"subject_id"`
+	Count		int64		`json:"count"`
+	ActorIDs	[]uuid.UUID	`json:"actor_ids"`
+	Summary		string		`json:"summary"`
+	Read		bool		`json:"read"`
+	LatestAt	time.Time	`json:"latest_at"`
+}
+
+// handlerListNotifications returns notification groups, newest first. Pass
+// unread=true for unread ones only, and the latest_at of the last group
+// received as before to get the next page.
+func(cfg *apiConfig) handlerListNotifications(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	params := database.ListNotificationGroupsParams{
+		UserID: user.ID,
+		Limit: 50,
+	}
+	if v := r.URL.Query().Get("unread"); v != "" {
+		unreadOnly, err := strconv.ParseBool(v)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid unread", err)
+			return
+		}
+		params.UnreadOnly = unreadOnly
+	}
+	if v := r.URL.Query().Get("before"); v != "" {
+		before, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "before must be an RFC 3339 time", err)
+			return
+		}
+		params.Before = sql.NullTime{Time: before, Valid: true}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		params.Limit = int32(limit)
+	}
+
+	groups, err := cfg.db.ListNotificationGroups(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list notifications", err)
+		return
+	}
+
+	response := []Notification{}
+	for _, group := range groups {
+		summary := group.Type
+		if summarize, ok := notificationSummaries[group.Type]; ok {
+			summary = summarize(group.Count, len(group.ActorIds))
+		}
+		response = append(response, Notification{
+			ID: group.GroupKey,
+			Type: group.Type,
+			SubjectType: group.SubjectType,
+			SubjectID: group.SubjectID,
+			Count: group.Count,
+			ActorIDs: group.ActorIds,
+			Summary: summary,
+			Read: group.ReadAt.Valid,
+			LatestAt: group.LatestAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func(cfg *apiConfig) handlerUnreadNotifications(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	count, err := cfg.db.CountUnreadNotifications(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't count notifications", err)
+		return
+	}
+
+	type response struct {
+		UnreadCount int64 `json:"unread_count"`
+	}
+	respondWithJSON(w, http.StatusOK, response{UnreadCount: count})
+}
+
+func(cfg *apiConfig) handlerMarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	_, err := cfg.db.MarkNotificationGroupRead(r.Context(), database.MarkNotificationGroupReadParams{
+		UserID: user.ID,
+		GroupKey: r.PathValue("notification_id"),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't mark notification read", err)
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+func(cfg *apiConfig) handlerMarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	if _, err := cfg.db.MarkAllNotificationsRead(r.Context(), user.ID); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't mark notifications read", err)
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// notificationPreferences returns whether each notification type is enabled
+// for userID. Types are on unless turned off.
+func(cfg *apiConfig) notificationPreferences(r *http.Request, userID uuid.UUID) (map[string]bool, error) {
+	prefs, err := cfg.db.ListNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	response := map[string]bool{}
+	for notificationType := range notificationSummaries {
+		response[notificationType] = true
+	}
+	for _, pref := range prefs {
+		if _, ok := response[pref.Type]; ok {
+			response[pref.Type] = pref.Enabled
+		}
+	}
+	return response, nil
+}
+
+func(cfg *apiConfig) handlerGetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	prefs, err := cfg.notificationPreferences(r, user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+// handlerUpdateNotificationPreferences takes a map of type to enabled. Types
+// that aren't mentioned are left as they are.
+func(cfg *apiConfig) handlerUpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	params := map[string]bool{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	for notificationType := range params {
+		if _, ok := notificationSummaries[notificationType]; !ok {
+			respondWithError(w, r, http.StatusBadRequest, "Unknown notification type "+strconv.Quote(notificationType), nil)
+			return
+		}
+	}
+
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		for notificationType, enabled := range params {
+			if err := q.SetNotificationPreference(r.Context(), database.SetNotificationPreferenceParams{
+				UserID: user.ID,
+				Type: notificationType,
+				Enabled: enabled,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update preferences", err)
+		return
+	}
+
+	prefs, err := cfg.notificationPreferences(r, user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
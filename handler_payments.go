@@ -6,6 +6,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/ppllama/chirpy/internal/auth"
+	"github.com/ppllama/chirpy/internal/database"
 )
 
 type PolkaWebhook struct {
@@ -19,40 +20,72 @@ func(cfg *apiConfig) handlerUpgradeUser(w http.ResponseWriter, r *http.Request)
 
 	apiKey, err := auth.GetAPIKey(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Error getting api key", err)
+		cfg.metrics.webhooks.WithLabelValues("unknown", "unauthorized").Inc()
+		respondWithError(w, r, http.StatusUnauthorized, "Error getting api key", err)
 		return
 	}
 
 	if apiKey != cfg.polka_key {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect ApiKey", err)
+		cfg.metrics.webhooks.WithLabelValues("unknown", "unauthorized").Inc()
+		respondWithError(w, r, http.StatusUnauthorized, "Incorrect ApiKey", err)
+		return
 	}
 
 	decoder := json.NewDecoder(r.Body)
 
 	requestData := PolkaWebhook{}
 	if err := decoder.Decode(&requestData); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error decoding parameters", err)
+		cfg.metrics.webhooks.WithLabelValues("unknown", "bad_request").Inc()
+		respondWithError(w, r, http.StatusInternalServerError, "Error decoding parameters", err)
 		return
 	}
 
 	if requestData.Event != "user.upgraded" {
+		cfg.metrics.webhooks.WithLabelValues("other", "ignored").Inc()
 		respondWithJSON(w, http.StatusNoContent, nil)
 		return
 	}
 
 	userID, err := uuid.Parse(requestData.Data.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error parsing user id", err)
+		cfg.metrics.webhooks.WithLabelValues(requestData.Event, "bad_request").Inc()
+		respondWithError(w, r, http.StatusInternalServerError, "Error parsing user id", err)
 		return
 	}
 
-	if err := cfg.db.UpgradeUser(r.Context(), userID); err != nil {
+	// Polka may send the same event more than once; only tell integrations
+	// about the first.
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		user, err := q.GetUserByID(r.Context(), userID)
+		if err != nil {
+			return err
+		}
+		if err := q.UpgradeUser(r.Context(), userID); err != nil || user.IsChirpyRed {
+			return err
+		}
+		return enqueueWebhook(r.Context(), q, webhookEvent{
+			Type: webhookUserUpgraded,
+			SubjectUserID: userID,
+			Data: map[string]any{"user_id": userID},
+		})
+	})
+	if err != nil {
 		if err.Error() == "sql: no rows in result set" {
-			respondWithError(w, http.StatusNotFound, "User not found", nil)
+			cfg.metrics.webhooks.WithLabelValues(requestData.Event, "not_found").Inc()
+			respondWithError(w, r, http.StatusNotFound, "User not found", nil)
 			return
 		}
-		respondWithError(w, http.StatusInternalServerError, "Error upgrading user", err)
+		cfg.metrics.webhooks.WithLabelValues(requestData.Event, "error").Inc()
+		respondWithError(w, r, http.StatusInternalServerError, "Error upgrading user", err)
+		return
 	}
 
+	cfg.metrics.webhooks.WithLabelValues(requestData.Event, "success").Inc()
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditUserUpgraded,
+		TargetType: "user",
+		TargetID: userID.String(),
+		Metadata: map[string]any{"source": "polka"},
+	})
 	respondWithJSON(w, http.StatusNoContent, nil)
 }
\ No newline at end of file
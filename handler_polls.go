@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+const (
+	minPollOptions = 2
+	maxPollOptions = 4
+	maxPollOptionLength = 50
+	minPollDuration = 5 * time.Minute
+	maxPollDuration = 7 * 24 * time.Hour
+)
+
+// Poll is a chirp's poll as seen by one viewer. Vote counts are left out
+// until the viewer has voted or the poll has closed, so that early results
+// don't sway anyone.
+type Poll struct {
+	ClosesAt		time.Time		`json:"closes_at"`
+	Closed			bool			`json:"closed"`
+	Options			[]PollOption	`json:"options"`
+	ResultsVisible	bool			`json:"results_visible"`
+	TotalVotes		*int64			`json:"total_votes,omitempty"`
+	// VotedOption is the index of the option the viewer voted for.
+	VotedOption		*int			`json:"voted_option,omitempty"`
+}
+
+type PollOption struct {
+	Text	string	`json:"text"`
+	Votes	*int64	`json:"votes,omitempty"`
+}
+
+// pollParams is the poll part of a new chirp.
+type pollParams struct {
+	Options		[]string	`json:"options"`
+	ClosesAt	time.Time	`json:"closes_at"`
+}
+
+// checkPoll validates a new poll, running its options through the content
+// filter like the chirp's body, and returns the options to store and how
+// long until it closes.
+func(cfg *apiConfig) checkPoll(p pollParams) ([]string, time.Duration, error) {
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return nil, 0, errors.New("A poll must have 2 to 4 options")
+	}
+	duration := time.Until(p.ClosesAt)
+	if duration < minPollDuration || duration > maxPollDuration {
+		return nil, 0, errors.New("A poll must close between 5 minutes and 7 days from now")
+	}
+
+	policy := cfg.filter.Current()
+	options := make([]string, 0, len(p.Options))
+	seen := map[string]bool{}
+	for _, option := range p.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > maxPollOptionLength {
+			return nil, 0, errors.New("Poll options must be 1 to 50 characters")
+		}
+		if seen[strings.ToLower(option)] {
+			return nil, 0, errors.New("Poll options must be different")
+		}
+		seen[strings.ToLower(option)] = true
+
+		filtered := policy.Apply(option)
+		if filtered.Rejected() {
+			return nil, 0, errors.New("Poll option contains a blocked word")
+		}
+		options = append(options, filtered.Text)
+	}
+	return options, duration, nil
+}
+
+// createPoll adds a poll checked by checkPoll to a chirp, in q's
+// transaction.
+func createPoll(ctx context.Context, q *database.Queries, chirpID uuid.UUID, options []string, duration time.Duration) error {
+	err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID: chirpID,
+		DelaySeconds: duration.Seconds(),
+	})
+	if err != nil {
+		return err
+	}
+	for i, option := range options {
+		err := q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID: chirpID,
+			Position: int32(i),
+			Text: option,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pollsFor returns the polls on the given chirps as viewer sees them, keyed
+// by chirp. Chirps without a poll are left out.
+func(cfg *apiConfig) pollsFor(ctx context.Context, chirpIDs []uuid.UUID, viewerID uuid.NullUUID) (map[uuid.UUID]*Poll, error) {
+	polls := map[uuid.UUID]*Poll{}
+	if len(chirpIDs) == 0 {
+		return polls, nil
+	}
+	rows, err := cfg.db.GetPollResults(ctx, database.GetPollResultsParams{
+		ViewerID: viewerID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	votes := map[uuid.UUID][]int64{}
+	for _, row := range rows {
+		poll := polls[row.ChirpID]
+		if poll == nil {
+			poll = &Poll{ClosesAt: row.ClosesAt, Closed: row.Closed}
+			polls[row.ChirpID] = poll
+		}
+		poll.Options = append(poll.Options, PollOption{Text: row.Text})
+		votes[row.ChirpID] = append(votes[row.ChirpID], row.Votes)
+		if row.ViewerVoted {
+			position := int(row.Position)
+			poll.VotedOption = &position
+		}
+	}
+
+	for chirpID, poll := range polls {
+		poll.ResultsVisible = poll.Closed || poll.VotedOption != nil
+		if !poll.ResultsVisible {
+			continue
+		}
+		var total int64
+		for i := range poll.Options {
+			poll.Options[i].Votes = &votes[chirpID][i]
+			total += votes[chirpID][i]
+		}
+		poll.TotalVotes = &total
+	}
+	return polls, nil
+}
+
+// handlerVote records the caller's vote in a chirp's poll and returns the
+// poll with its results. Votes can't be changed.
+func(cfg *apiConfig) handlerVote(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Option *int `json:"option"`
+	}
+
+	user, _ := authUserFromContext(r.Context())
+	viewerID := uuid.NullUUID{UUID: user.ID, Valid: true}
+
+	chirpID, err := pathUUID(r, "chirp_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid chirp ID", err)
+		return
+	}
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	// Only chirps the caller can see can be voted on.
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && chirp.HiddenAt.Valid) {
+		respondWithError(w, r, http.StatusNotFound, "Poll not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't vote", err)
+		return
+	}
+	blocked, err := cfg.db.IsBlockedEitherWay(r.Context(), database.IsBlockedEitherWayParams{
+		UserID: user.ID,
+		TargetID: chirp.UserID,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't vote", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, r, http.StatusNotFound, "Poll not found", nil)
+		return
+	}
+	polls, err := cfg.pollsFor(r.Context(), []uuid.UUID{chirpID}, viewerID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't vote", err)
+		return
+	}
+	poll := polls[chirpID]
+	if poll == nil {
+		respondWithError(w, r, http.StatusNotFound, "Poll not found", nil)
+		return
+	}
+
+	if params.Option == nil || *params.Option < 0 || *params.Option >= len(poll.Options) {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid option", nil)
+		return
+	}
+	if poll.Closed {
+		respondWithError(w, r, http.StatusConflict, "Poll has closed", nil)
+		return
+	}
+	if poll.VotedOption != nil {
+		respondWithError(w, r, http.StatusConflict, "You have already voted", nil)
+		return
+	}
+
+	voted, err := cfg.db.CastPollVote(r.Context(), database.CastPollVoteParams{
+		UserID: user.ID,
+		Position: int32(*params.Option),
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't vote", err)
+		return
+	}
+	if voted == 0 {
+		respondWithError(w, r, http.StatusConflict, "You have already voted or the poll has closed", nil)
+		return
+	}
+
+	polls, err = cfg.pollsFor(r.Context(), []uuid.UUID{chirpID}, viewerID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get poll", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, polls[chirpID])
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+// Relationship kinds. Blocking hides both users' chirps from each other;
+// muting only hides the muted user's chirps from the muter, who stays
+// visible to them.
+const (
+	relationshipBlock = "block"
+	relationshipMute = "mute"
+)
+
+type UserRelationship struct {
+	UserID		uuid.UUID	`json:"user_id"`
+	CreatedAt	time.Time	`json:"created_at"`
+}
+
+// handlerCreateRelationship returns the handler that blocks or mutes the
+// user_id in the request body on behalf of the caller.
+func(cfg *apiConfig) handlerCreateRelationship(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		type parameters struct {
+			UserID uuid.UUID `json:"user_id"`
+		}
+
+		user, _ := authUserFromContext(r.Context())
+
+		params := parameters{}
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+			return
+		}
+		if params.UserID == user.ID {
+			respondWithError(w, r, http.StatusBadRequest, "You can't "+kind+" yourself", nil)
+			return
+		}
+
+		if _, err := cfg.db.GetUserByID(r.Context(), params.UserID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, r, http.StatusNotFound, "User not found", nil)
+				return
+			}
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+			return
+		}
+
+		err := cfg.db.CreateUserRelationship(r.Context(), database.CreateUserRelationshipParams{
+			UserID: user.ID,
+			TargetID: params.UserID,
+			Kind: kind,
+		})
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't "+kind+" user", err)
+			return
+		}
+		respondWithJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func(cfg *apiConfig) handlerDeleteRelationship(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := authUserFromContext(r.Context())
+
+		targetID, err := pathUUID(r, "user_id")
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid user ID", err)
+			return
+		}
+
+		deleted, err := cfg.db.DeleteUserRelationship(r.Context(), database.DeleteUserRelationshipParams{
+			UserID: user.ID,
+			TargetID: targetID,
+			Kind: kind,
+		})
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't un"+kind+" user", err)
+			return
+		}
+		if deleted == 0 {
+			respondWithError(w, r, http.StatusNotFound, "No "+kind+" for that user", nil)
+			return
+		}
+		respondWithJSON(w, http.StatusNoContent, nil)
+	}
+}
+
+func(cfg *apiConfig) handlerListRelationships(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, _ := authUserFromContext(r.Context())
+
+		relationships, err := cfg.db.ListUserRelationships(r.Context(), database.ListUserRelationshipsParams{
+			UserID: user.ID,
+			Kind: kind,
+		})
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't list users", err)
+			return
+		}
+
+		response := []UserRelationship{}
+		for _, relationship := range relationships {
+			response = append(response, UserRelationship{
+				UserID: relationship.TargetID,
+				CreatedAt: relationship.CreatedAt,
+			})
+		}
+		respondWithJSON(w, http.StatusOK, response)
+	}
+}
@@ -0,0 +1,519 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/auth"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+const maxReportReasonLength = 1000
+
+const (
+	reportActionDismiss = "dismiss"
+	reportActionHideChirp = "hide_chirp"
+	reportActionSuspendUser = "suspend_user"
+)
+
+// reportResolutions maps the action a moderator takes to the resolution
+// stored on the report.
+var reportResolutions = map[string]string{
+	reportActionDismiss: "dismissed",
+	reportActionHideChirp: "chirp_hidden",
+	reportActionSuspendUser: "user_suspended",
+}
+
+type Report struct {
+	ID				uuid.UUID		`json:"id"`
+	CreatedAt		time.Time		`json:"created_at"`
+	UpdatedAt		time.Time		`json:"updated_at"`
+	ReporterID		*uuid.UUID		`json:"reporter_id"`
+	ReportedUserID	uuid.UUID		`json:"reported_user_id"`
+	ChirpID			*uuid.UUID		`json:"chirp_id"`
+	Reason			string			`json:"reason"`
+	Status			string			`json:"status"`
+	AssigneeID		*uuid.UUID		`json:"assignee_id,omitempty"`
+	Resolution		string			`json:"resolution,omitempty"`
+	ResolvedBy		*uuid.UUID		`json:"resolved_by,omitempty"`
+	ResolvedAt		*time.Time		`json:"resolved_at,omitempty"`
+	Notes			[]ReportNote	`json:"notes,omitempty"`
+}
+
+type ReportNote struct {
+	ID			uuid.UUID	`json:"id"`
+	CreatedAt	time.Time	`json:"created_at"`
+	AuthorID	*uuid.UUID	`json:"author_id"`
+	Body		string		`json:"body"`
+}
+
+func reportFromDB(report database.Report) Report {
+	response := Report{
+		ID: report.ID,
+		CreatedAt: report.CreatedAt,
+		UpdatedAt: report.UpdatedAt,
+		ReportedUserID: report.ReportedUserID,
+		Reason: report.Reason,
+		Status: report.Status,
+		Resolution: report.Resolution.String,
+	}
+	if report.ReporterID.Valid {
+		response.ReporterID = &report.ReporterID.UUID
+	}
+	if report.ChirpID.Valid {
+		response.ChirpID = &report.ChirpID.UUID
+	}
+	if report.AssigneeID.Valid {
+		response.AssigneeID = &report.AssigneeID.UUID
+	}
+	if report.ResolvedBy.Valid {
+		response.ResolvedBy = &report.ResolvedBy.UUID
+	}
+	if report.ResolvedAt.Valid {
+		response.ResolvedAt = &report.ResolvedAt.Time
+	}
+	return response
+}
+
+func reportNoteFromDB(note database.ReportNote) ReportNote {
+	response := ReportNote{
+		ID: note.ID,
+		CreatedAt: note.CreatedAt,
+		Body: note.Body,
+	}
+	if note.AuthorID.Valid {
+		response.AuthorID = &note.AuthorID.UUID
+	}
+	return response
+}
+
+// handlerCreateReport lets a user report a chirp (chirp_id) or an account
+// (user_id). Reports on a chirp are also filed against its author.
+func(cfg *apiConfig) handlerCreateReport(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		ChirpID	*uuid.UUID	`json:"chirp_id"`
+		UserID	*uuid.UUID	`json:"user_id"`
+		Reason	string		`json:"reason"`
+	}
+
+	reporter, _ := authUserFromContext(r.Context())
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Reason == "" {
+		respondWithError(w, r, http.StatusBadRequest, "A reason is required", nil)
+		return
+	}
+	if len(params.Reason) > maxReportReasonLength {
+		respondWithError(w, r, http.StatusBadRequest, "Reason is too long", nil)
+		return
+	}
+
+	createParams := database.CreateReportParams{
+		ReporterID: uuid.NullUUID{UUID: reporter.ID, Valid: true},
+		Reason: params.Reason,
+	}
+	switch {
+	case params.ChirpID != nil:
+		chirp, err := cfg.db.GetChirp(r.Context(), *params.ChirpID)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && chirp.HiddenAt.Valid) {
+			respondWithError(w, r, http.StatusNotFound, "Chirp not found", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Could not get chirp", err)
+			return
+		}
+		createParams.ChirpID = uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		createParams.ReportedUserID = chirp.UserID
+	case params.UserID != nil:
+		user, err := cfg.db.GetUserByID(r.Context(), *params.UserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "User not found", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Could not get user", err)
+			return
+		}
+		createParams.ReportedUserID = user.ID
+	default:
+		respondWithError(w, r, http.StatusBadRequest, "chirp_id or user_id is required", nil)
+		return
+	}
+
+	if createParams.ReportedUserID == reporter.ID {
+		respondWithError(w, r, http.StatusBadRequest, "You can't report yourself", nil)
+		return
+	}
+
+	report, err := cfg.db.CreateReport(r.Context(), createParams)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not create report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reporterView(report))
+}
+
+// reporterView is what a reporter sees of their own report: its outcome, but
+// not which moderator handled it.
+func reporterView(report database.Report) Report {
+	response := reportFromDB(report)
+	response.AssigneeID = nil
+	response.ResolvedBy = nil
+	return response
+}
+
+// handlerListMyReports lets reporters follow up on what they reported and
+// how each report was resolved.
+func(cfg *apiConfig) handlerListMyReports(w http.ResponseWriter, r *http.Request) {
+	reporter, _ := authUserFromContext(r.Context())
+
+	reports, err := cfg.db.ListReportsByReporter(r.Context(), uuid.NullUUID{UUID: reporter.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not list reports", err)
+		return
+	}
+
+	response := []Report{}
+	for _, report := range reports {
+		response = append(response, reporterView(report))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerListReports serves the moderation queue, oldest first, optionally
+// filtered by status.
+func(cfg *apiConfig) handlerListReports(w http.ResponseWriter, r *http.Request) {
+	params := database.ListReportsParams{Limit: 50}
+
+	if status := r.URL.Query().Get("status"); status != "" {
+		if status != "open" && status != "claimed" && status != "resolved" {
+			respondWithError(w, r, http.StatusBadRequest, "status must be open, claimed or resolved", nil)
+			return
+		}
+		params.Status = sql.NullString{String: status, Valid: true}
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 500 {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+		params.Limit = int32(limit)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid offset", err)
+			return
+		}
+		params.Offset = int32(offset)
+	}
+
+	reports, err := cfg.db.ListReports(r.Context(), params)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not list reports", err)
+		return
+	}
+
+	response := []Report{}
+	for _, report := range reports {
+		response = append(response, reportFromDB(report))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func(cfg *apiConfig) handlerGetReport(w http.ResponseWriter, r *http.Request) {
+	id, err := pathUUID(r, "report_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	report, err := cfg.db.GetReport(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Report not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not get report", err)
+		return
+	}
+
+	notes, err := cfg.db.ListReportNotes(r.Context(), report.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not get report notes", err)
+		return
+	}
+
+	response := reportFromDB(report)
+	response.Notes = []ReportNote{}
+	for _, note := range notes {
+		response.Notes = append(response.Notes, reportNoteFromDB(note))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerClaimReport assigns an open report to the calling moderator. Claiming
+// a report you already hold is a no-op; one held by someone else is a 409.
+func(cfg *apiConfig) handlerClaimReport(w http.ResponseWriter, r *http.Request) {
+	moderator, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "report_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	report, err := cfg.db.ClaimReport(r.Context(), database.ClaimReportParams{
+		ID: id,
+		AssigneeID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := cfg.db.GetReport(r.Context(), id); errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "Report not found", nil)
+			return
+		}
+		respondWithError(w, r, http.StatusConflict, "Report is already claimed or resolved", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not claim report", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDB(report))
+}
+
+func(cfg *apiConfig) handlerAddReportNote(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	moderator, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "report_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		respondWithError(w, r, http.StatusBadRequest, "Note body is required", nil)
+		return
+	}
+
+	if _, err := cfg.db.GetReport(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "Report not found", nil)
+			return
+		}
+		respondWithError(w, r, http.StatusInternalServerError, "Could not get report", err)
+		return
+	}
+
+	note, err := cfg.db.CreateReportNote(r.Context(), database.CreateReportNoteParams{
+		ReportID: id,
+		AuthorID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Body: params.Body,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Could not add note", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, reportNoteFromDB(note))
+}
+
+var errReportResolved = errors.New("report already resolved")
+var errReportClaimed = errors.New("report claimed by another moderator")
+var errReportHasNoChirp = errors.New("report is not about a chirp")
+
+// handlerResolveReport closes a report with one of dismiss, hide_chirp or
+// suspend_user. The resolution, its side effect and the optional note are
+// applied in a single transaction. A report another moderator has claimed
+// can only be resolved by them or an admin, and suspend_user is held to the
+// same rules as handlerSetUserStatus.
+func(cfg *apiConfig) handlerResolveReport(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Action	string	`json:"action"`
+		Note	string	`json:"note"`
+	}
+
+	moderator, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "report_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid report ID", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	resolution, ok := reportResolutions[params.Action]
+	if !ok {
+		respondWithError(w, r, http.StatusBadRequest, "action must be dismiss, hide_chirp or suspend_user", nil)
+		return
+	}
+
+	// The reported user can't change, so whether the moderator may suspend
+	// them is settled before taking the lock.
+	if params.Action == reportActionSuspendUser {
+		report, err := cfg.db.GetReport(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "Report not found", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Could not get report", err)
+			return
+		}
+		target, err := cfg.db.GetUserByID(r.Context(), report.ReportedUserID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, r, http.StatusNotFound, "User not found", nil)
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+			return
+		}
+		switch err := authorizeStatusChange(moderator, target); {
+		case errors.Is(err, errStatusOwnAccount):
+			respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+			return
+		case err != nil:
+			respondWithError(w, r, http.StatusForbidden, err.Error(), nil)
+			return
+		}
+	}
+
+	var resolved database.Report
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		report, err := q.LockReport(r.Context(), id)
+		if err != nil {
+			return err
+		}
+		if report.Status == "resolved" {
+			return errReportResolved
+		}
+		claimedByOther := report.AssigneeID.Valid && report.AssigneeID.UUID != moderator.ID
+		if claimedByOther && !auth.HasRole(moderator.Role, auth.RoleAdmin) {
+			return errReportClaimed
+		}
+		if params.Action == reportActionHideChirp && !report.ChirpID.Valid {
+			return errReportHasNoChirp
+		}
+
+		resolved, err = q.ResolveReport(r.Context(), database.ResolveReportParams{
+			ID: id,
+			Resolution: sql.NullString{String: resolution, Valid: true},
+			ModeratorID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return errReportResolved
+		}
+		if err != nil {
+			return err
+		}
+
+		switch params.Action {
+		case reportActionHideChirp:
+			if err := q.HideChirp(r.Context(), report.ChirpID.UUID); err != nil {
+				return err
+			}
+			if err := enqueueWebhook(r.Context(), q, chirpDeletedEvent(report.ChirpID.UUID, report.ReportedUserID, "hidden")); err != nil {
+				return err
+			}
+		case reportActionSuspendUser:
+			if err := setUserStatus(r.Context(), q, report.ReportedUserID, userStatusSuspended, "report "+report.ID.String(), 0); err != nil {
+				return err
+			}
+		}
+
+		if strings.TrimSpace(params.Note) != "" {
+			if _, err := q.CreateReportNote(r.Context(), database.CreateReportNoteParams{
+				ReportID: id,
+				AuthorID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+				Body: params.Note,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		respondWithError(w, r, http.StatusNotFound, "Report not found", nil)
+		return
+	case errors.Is(err, errReportResolved):
+		respondWithError(w, r, http.StatusConflict, "Report is already resolved", nil)
+		return
+	case errors.Is(err, errReportClaimed):
+		respondWithError(w, r, http.StatusConflict, "Report is claimed by another moderator", nil)
+		return
+	case errors.Is(err, errReportHasNoChirp):
+		respondWithError(w, r, http.StatusBadRequest, "Report is not about a chirp", nil)
+		return
+	case err != nil:
+		respondWithError(w, r, http.StatusInternalServerError, "Could not resolve report", err)
+		return
+	}
+
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditReportResolved,
+		ActorID: moderator.ID,
+		TargetType: "report",
+		TargetID: resolved.ID.String(),
+		Metadata: map[string]any{"resolution": resolution},
+	})
+	switch params.Action {
+	case reportActionHideChirp:
+		recordAudit(r.Context(), cfg.db, r, auditEntry{
+			Action: auditChirpHidden,
+			ActorID: moderator.ID,
+			TargetType: "chirp",
+			TargetID: resolved.ChirpID.UUID.String(),
+			Metadata: map[string]any{"report_id": resolved.ID},
+		})
+	case reportActionSuspendUser:
+		recordAudit(r.Context(), cfg.db, r, auditEntry{
+			Action: auditUserSuspended,
+			ActorID: moderator.ID,
+			TargetType: "user",
+			TargetID: resolved.ReportedUserID.String(),
+			Metadata: map[string]any{"report_id": resolved.ID},
+		})
+	}
+
+	if resolved.ReporterID.Valid {
+		notify(r.Context(), cfg.db, notification{
+			UserID: resolved.ReporterID.UUID,
+			Type: notificationReportResolved,
+			SubjectType: "report",
+			SubjectID: resolved.ID.String(),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, reportFromDB(resolved))
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/jobs"
+)
+
+const (
+	maxScheduledChirps = 100
+	maxScheduleAhead = 365 * 24 * time.Hour
+)
+
+var errAuthorSuspended = errors.New("Account is suspended")
+
+type ScheduledChirp struct {
+	ID			uuid.UUID	`json:"id"`
+	CreatedAt	time.Time	`json:"created_at"`
+	UpdatedAt	time.Time	`json:"updated_at"`
+	Body		string		`json:"body"`
+	PublishAt	time.Time	`json:"publish_at"`
+	Status		string		`json:"status"`
+	Error		string		`json:"error,omitempty"`
+}
+
+func scheduledChirpFromDB(scheduled database.ScheduledChirp) ScheduledChirp {
+	return ScheduledChirp{
+		ID: scheduled.ID,
+		CreatedAt: scheduled.CreatedAt,
+		UpdatedAt: scheduled.UpdatedAt,
+		Body: scheduled.Body,
+		PublishAt: scheduled.PublishAt,
+		Status: scheduled.Status,
+		Error: scheduled.Error.String,
+	}
+}
+
+type publishChirpArgs struct {
+	ScheduledChirpID uuid.UUID `json:"scheduled_chirp_id"`
+}
+
+var jobPublishChirp = jobs.Kind[publishChirpArgs]{Name: "chirp.publish"}
+
+// scheduleDelay checks that publishAt is in the allowed range and returns how
+// long until then. Scheduled times are stored relative to the database's
+// clock, like every other timestamp.
+func scheduleDelay(publishAt time.Time) (time.Duration, error) {
+	delay := time.Until(publishAt)
+	if delay <= 0 {
+		return 0, errors.New("publish_at must be in the future")
+	}
+	if delay > maxScheduleAhead {
+		return 0, errors.New("publish_at must be within a year")
+	}
+	return delay, nil
+}
+
+// scheduleChirp handles POST /api/chirps with a publish_at. The body is
+// checked now so that mistakes are caught straight away, and again when the
+// chirp is published, in case the content filter has changed.
+func(cfg *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request, userID uuid.UUID, body string, publishAt time.Time) {
+	delay, err := scheduleDelay(publishAt)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+	if _, err := cfg.checkChirp(body); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	count, err := cfg.db.CountScheduledChirps(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't schedule chirp", err)
+		return
+	}
+	if count >= maxScheduledChirps {
+		respondWithError(w, r, http.StatusBadRequest, "You can have at most "+strconv.Itoa(maxScheduledChirps)+" scheduled chirps", nil)
+		return
+	}
+
+	var scheduled database.ScheduledChirp
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		scheduled, err = q.CreateScheduledChirp(r.Context(), database.CreateScheduledChirpParams{
+			UserID: userID,
+			Body: body,
+			DelaySeconds: delay.Seconds(),
+		})
+		if err != nil {
+			return err
+		}
+		return enqueueJob(r.Context(), q, jobPublishChirp, publishChirpArgs{ScheduledChirpID: scheduled.ID}, delay)
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't schedule chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, scheduledChirpFromDB(scheduled))
+}
+
+// handlerListScheduledChirps returns the caller's chirps that are waiting to
+// be published, or that failed to be, soonest first.
+func(cfg *apiConfig) handlerListScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	scheduled, err := cfg.db.ListScheduledChirps(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list scheduled chirps", err)
+		return
+	}
+
+	response := []ScheduledChirp{}
+	for _, s := range scheduled {
+		response = append(response, scheduledChirpFromDB(s))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// ownedScheduledChirp loads the scheduled chirp named in the path, responding
+// 404 if it doesn't exist or belongs to someone else.
+func(cfg *apiConfig) ownedScheduledChirp(w http.ResponseWriter, r *http.Request) (database.ScheduledChirp, bool) {
+	user, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "scheduled_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid scheduled chirp ID", err)
+		return database.ScheduledChirp{}, false
+	}
+	scheduled, err := cfg.db.GetScheduledChirp(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && scheduled.UserID != user.ID) {
+		respondWithError(w, r, http.StatusNotFound, "Scheduled chirp not found", nil)
+		return database.ScheduledChirp{}, false
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get scheduled chirp", err)
+		return database.ScheduledChirp{}, false
+	}
+	return scheduled, true
+}
+
+// handlerUpdateScheduledChirp changes the body or publish_at of a scheduled
+// chirp; fields left out keep their value. Saving a failed chirp queues it
+// again, straight away if its time has passed.
+func(cfg *apiConfig) handlerUpdateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Body		*string		`json:"body"`
+		PublishAt	*time.Time	`json:"publish_at"`
+	}
+
+	scheduled, ok := cfg.ownedScheduledChirp(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+
+	update := database.UpdateScheduledChirpParams{
+		ID: scheduled.ID,
+		Body: scheduled.Body,
+	}
+	if params.Body != nil {
+		update.Body = *params.Body
+	}
+	if params.PublishAt != nil {
+		delay, err := scheduleDelay(*params.PublishAt)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+			return
+		}
+		update.DelaySeconds = sql.NullFloat64{Float64: delay.Seconds(), Valid: true}
+	}
+	if _, err := cfg.checkChirp(update.Body); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	// The job queued for the old time finds the chirp isn't due and does
+	// nothing, so queue one for the new time.
+	err := cfg.withTx(r.Context(), func(q *database.Queries) error {
+		var err error
+		scheduled, err = q.UpdateScheduledChirp(r.Context(), update)
+		if err != nil {
+			return err
+		}
+		var delay time.Duration
+		if update.DelaySeconds.Valid {
+			delay = time.Duration(update.DelaySeconds.Float64 * float64(time.Second))
+		}
+		return enqueueJob(r.Context(), q, jobPublishChirp, publishChirpArgs{ScheduledChirpID: scheduled.ID}, delay)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "Scheduled chirp not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update scheduled chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, scheduledChirpFromDB(scheduled))
+}
+
+func(cfg *apiConfig) handlerCancelScheduledChirp(w http.ResponseWriter, r *http.Request) {
+	scheduled, ok := cfg.ownedScheduledChirp(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.db.DeleteScheduledChirp(r.Context(), scheduled.ID); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't cancel scheduled chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// publishScheduledChirp runs a chirp.publish job, posting the chirp exactly
+// as if it had been posted then. If it is no longer due, because it was
+// cancelled, edited or already published, there is nothing to do. If it
+// can't be posted, it is marked failed with the reason.
+func(cfg *apiConfig) publishScheduledChirp(ctx context.Context, job jobs.Job, args publishChirpArgs) error {
+	err := cfg.withTx(ctx, func(q *database.Queries) error {
+		scheduled, err := q.LockDueScheduledChirp(ctx, args.ScheduledChirpID)
+		if err != nil {
+			return err
+		}
+
+		author, err := q.GetUserByID(ctx, scheduled.UserID)
+		if err != nil {
+			return err
+		}
+		author, err = cfg.liftExpiredStatus(ctx, author)
+		if err != nil {
+			return err
+		}
+		if author.Status == userStatusSuspended {
+			return errAuthorSuspended
+		}
+
+		filtered, err := cfg.checkChirp(scheduled.Body)
+		for _, match := range filtered.Matches {
+			cfg.metrics.filterMatches.WithLabelValues(string(match.Action)).Inc()
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := cfg.createChirp(ctx, q, scheduled.UserID, filtered); err != nil {
+			return err
+		}
+		return q.DeleteScheduledChirp(ctx, scheduled.ID)
+	})
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case errors.Is(err, errChirpTooLong), errors.Is(err, errChirpBlocked), errors.Is(err, errAuthorSuspended):
+		return cfg.db.FailScheduledChirp(ctx, database.FailScheduledChirpParams{
+			ID: args.ScheduledChirpID,
+			Error: sql.NullString{String: err.Error(), Valid: true},
+		})
+	case err != nil:
+		return err
+	}
+	cfg.metrics.chirpsCreated.Inc()
+	return nil
+}
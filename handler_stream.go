@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/stream"
+)
+
+const (
+	streamHeartbeat = 15 * time.Second
+	streamReplayLimit = 1000
+)
+
+// timelineFilter reports whether a timeline event should reach viewerID,
+// following the same rules as GET /api/chirps: no chirps across a block or
+// from muted users, and limited users' chirps only to themselves. The block
+// and mute lists are read once, when the subscriber connects.
+func(cfg *apiConfig) timelineFilter(ctx context.Context, viewerID uuid.NullUUID) (func(stream.Event) bool, error) {
+	hidden := map[uuid.UUID]bool{}
+	if viewerID.Valid {
+		ids, err := cfg.db.ListStreamHiddenAuthors(ctx, viewerID.UUID)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			hidden[id] = true
+		}
+	}
+
+	return func(e stream.Event) bool {
+		if hidden[e.AuthorID] {
+			return false
+		}
+		if e.AuthorLimited && (!viewerID.Valid || viewerID.UUID != e.AuthorID) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+// handlerStream pushes chirp.created and chirp.deleted events as Server-Sent
+// Events. A client that reconnects with Last-Event-ID is sent what it missed
+// first. The same visibility rules as GET /api/chirps apply.
+func(cfg *apiConfig) handlerStream(w http.ResponseWriter, r *http.Request) {
+	var authorID uuid.NullUUID
+	if v := r.URL.Query().Get("author_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid author_id", err)
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	var lastEventID int64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid Last-Event-ID", err)
+			return
+		}
+		lastEventID = id
+	}
+
+	timelineVisible, err := cfg.timelineFilter(r.Context(), cfg.optionalViewer(r))
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't open stream", err)
+		return
+	}
+	visible := func(e stream.Event) bool {
+		if authorID.Valid && e.AuthorID != authorID.UUID {
+			return false
+		}
+		return timelineVisible(e)
+	}
+
+	// Subscribe before replaying so nothing published in between is missed;
+	// events already replayed are skipped below.
+	sub := cfg.stream.Subscribe(func(e stream.Event) bool { return e.Topic == topicTimeline })
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	// Streams outlive the server's WriteTimeout.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't open stream", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// Events can commit out of id order, so the client may have missed
+	// events with lower ids than the last one it saw. Everything it could
+	// have missed was written by a transaction no older than the horizon
+	// recorded with that event. Replaying from there may repeat a few events
+	// from just before it.
+	replayed := map[int64]bool{}
+	if lastEventID > 0 {
+		params := database.ListChirpEventsParams{Limit: streamReplayLimit}
+		horizon, err := cfg.db.GetChirpEventTxHorizon(r.Context(), lastEventID)
+		switch {
+		case err == nil:
+			params.MinTxID = horizon
+		case errors.Is(err, sql.ErrNoRows):
+			// Too old to still be kept; replay what there is after it.
+			params.AfterID = lastEventID
+		default:
+			return
+		}
+		rows, err := cfg.db.ListChirpEvents(r.Context(), params)
+		if err != nil {
+			return
+		}
+		for _, row := range rows {
+			if row.ID == lastEventID {
+				continue
+			}
+			replayed[row.ID] = true
+			if event, ok := chirpEventFromDB(row); ok && visible(event) {
+				if err := stream.Write(w, event); err != nil {
+					return
+				}
+			}
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			// Closed when we fell too far behind or the server is shutting
+			// down; the client reconnects with Last-Event-ID.
+			if !ok {
+				return
+			}
+			if replayed[event.ID] || !visible(event) {
+				continue
+			}
+			if err := stream.Write(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := stream.WriteHeartbeat(w); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
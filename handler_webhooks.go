@@ -0,0 +1,346 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/auth"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/webhook"
+)
+
+const maxWebhooksPerUser = 10
+
+// Webhook is an outbound webhook endpoint. Secret is only included when the
+// webhook is created.
+type Webhook struct {
+	ID			uuid.UUID	`json:"id"`
+	CreatedAt	time.Time	`json:"created_at"`
+	UpdatedAt	time.Time	`json:"updated_at"`
+	URL			string		`json:"url"`
+	Events		[]string	`json:"events"`
+	Global		bool		`json:"global"`
+	Secret		string		`json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID				uuid.UUID		`json:"id"`
+	CreatedAt		time.Time		`json:"created_at"`
+	EventID			uuid.UUID		`json:"event_id"`
+	Event			string			`json:"event"`
+	Payload			json.RawMessage	`json:"payload"`
+	Status			string			`json:"status"`
+	Attempts		int32			`json:"attempts"`
+	NextAttemptAt	*time.Time		`json:"next_attempt_at,omitempty"`
+	LastAttemptAt	*time.Time		`json:"last_attempt_at,omitempty"`
+	ResponseStatus	*int32			`json:"response_status,omitempty"`
+	LastError		string			`json:"last_error,omitempty"`
+}
+
+func webhookFromDB(hook database.Webhook) Webhook {
+	return Webhook{
+		ID: hook.ID,
+		CreatedAt: hook.CreatedAt,
+		UpdatedAt: hook.UpdatedAt,
+		URL: hook.Url,
+		Events: hook.Events,
+		Global: hook.Global,
+	}
+}
+
+func webhookDeliveryFromDB(d database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID: d.ID,
+		CreatedAt: d.CreatedAt,
+		EventID: d.EventID,
+		Event: d.EventType,
+		Payload: d.Payload,
+		Status: d.Status,
+		Attempts: d.Attempts,
+		LastError: d.LastError.String,
+	}
+	if d.Status == "pending" {
+		delivery.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.LastAttemptAt.Valid {
+		delivery.LastAttemptAt = &d.LastAttemptAt.Time
+	}
+	if d.ResponseStatus.Valid {
+		delivery.ResponseStatus = &d.ResponseStatus.Int32
+	}
+	return delivery
+}
+
+// validateWebhook checks a webhook's URL and events, returning the events
+// with duplicates removed. Plain http is only allowed on the dev platform.
+func(cfg *apiConfig) validateWebhook(rawURL string, events []string) ([]string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && !(u.Scheme == "http" && cfg.platform == "dev")) {
+		return nil, errors.New("url must be an absolute https URL")
+	}
+	if len(events) == 0 {
+		return nil, errors.New("events must not be empty")
+	}
+	var unique []string
+	for _, event := range events {
+		if !slices.Contains(webhookEvents, event) {
+			return nil, errors.New("unknown event " + strconv.Quote(event))
+		}
+		if !slices.Contains(unique, event) {
+			unique = append(unique, event)
+		}
+	}
+	return unique, nil
+}
+
+// ownedWebhook loads the webhook named in the path, responding 404 if it
+// doesn't exist or belongs to someone else.
+func(cfg *apiConfig) ownedWebhook(w http.ResponseWriter, r *http.Request) (database.Webhook, bool) {
+	user, _ := authUserFromContext(r.Context())
+
+	id, err := pathUUID(r, "webhook_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid webhook ID", err)
+		return database.Webhook{}, false
+	}
+	hook, err := cfg.db.GetWebhook(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && hook.OwnerID != user.ID) {
+		respondWithError(w, r, http.StatusNotFound, "Webhook not found", nil)
+		return database.Webhook{}, false
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get webhook", err)
+		return database.Webhook{}, false
+	}
+	return hook, true
+}
+
+// handlerCreateWebhook registers a webhook for the caller. Only admins may
+// create global webhooks, which receive events about every user.
+func(cfg *apiConfig) handlerCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL		string		`json:"url"`
+		Events	[]string	`json:"events"`
+		Global	bool		`json:"global"`
+	}
+
+	user, _ := authUserFromContext(r.Context())
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if params.Global && !auth.HasRole(user.Role, auth.RoleAdmin) {
+		respondWithError(w, r, http.StatusForbidden, "Only admins can create global webhooks", nil)
+		return
+	}
+	events, err := cfg.validateWebhook(params.URL, params.Events)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	count, err := cfg.db.CountWebhooksByOwner(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create webhook", err)
+		return
+	}
+	if count >= maxWebhooksPerUser {
+		respondWithError(w, r, http.StatusBadRequest, "You can have at most "+strconv.Itoa(maxWebhooksPerUser)+" webhooks", nil)
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create webhook", err)
+		return
+	}
+	hook, err := cfg.db.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		OwnerID: user.ID,
+		Global: params.Global,
+		Url: params.URL,
+		Secret: secret,
+		Events: events,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't create webhook", err)
+		return
+	}
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditWebhookCreated,
+		ActorID: user.ID,
+		TargetType: "webhook",
+		TargetID: hook.ID.String(),
+		Metadata: map[string]any{"url": hook.Url, "events": hook.Events, "global": hook.Global},
+	})
+
+	response := webhookFromDB(hook)
+	response.Secret = hook.Secret
+	respondWithJSON(w, http.StatusCreated, response)
+}
+
+func(cfg *apiConfig) handlerListWebhooks(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	hooks, err := cfg.db.ListWebhooksByOwner(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list webhooks", err)
+		return
+	}
+
+	response := []Webhook{}
+	for _, hook := range hooks {
+		response = append(response, webhookFromDB(hook))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func(cfg *apiConfig) handlerGetWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookFromDB(hook))
+}
+
+// handlerUpdateWebhook replaces a webhook's URL and events. Its secret and
+// whether it is global stay the same.
+func(cfg *apiConfig) handlerUpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL		string		`json:"url"`
+		Events	[]string	`json:"events"`
+	}
+
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	events, err := cfg.validateWebhook(params.URL, params.Events)
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	hook, err = cfg.db.UpdateWebhook(r.Context(), database.UpdateWebhookParams{
+		ID: hook.ID,
+		Url: params.URL,
+		Events: events,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update webhook", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookFromDB(hook))
+}
+
+func(cfg *apiConfig) handlerDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.db.DeleteWebhook(r.Context(), hook.ID); err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't delete webhook", err)
+		return
+	}
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditWebhookDeleted,
+		ActorID: user.ID,
+		TargetType: "webhook",
+		TargetID: hook.ID.String(),
+		Metadata: map[string]any{"url": hook.Url},
+	})
+	respondWithJSON(w, http.StatusNoContent, nil)
+}
+
+// handlerListWebhookDeliveries returns a webhook's most recent deliveries,
+// newest first.
+func(cfg *apiConfig) handlerListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			respondWithError(w, r, http.StatusBadRequest, "Invalid limit", err)
+			return
+		}
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		WebhookID: hook.ID,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't list deliveries", err)
+		return
+	}
+
+	response := []WebhookDelivery{}
+	for _, d := range deliveries {
+		response = append(response, webhookDeliveryFromDB(d))
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// handlerTestWebhook sends a webhook.test event to the webhook straight away
+// and returns the resulting delivery, so integrators can check their
+// endpoint and signature verification. Failed test deliveries aren't
+// retried.
+func(cfg *apiConfig) handlerTestWebhook(w http.ResponseWriter, r *http.Request) {
+	user, _ := authUserFromContext(r.Context())
+
+	hook, ok := cfg.ownedWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	eventID, payload, err := webhookEvent{
+		Type: webhookTest,
+		SubjectUserID: user.ID,
+		Data: map[string]any{"webhook_id": hook.ID},
+	}.payload()
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't send test event", err)
+		return
+	}
+	delivery, err := cfg.db.CreateWebhookDelivery(r.Context(), database.CreateWebhookDeliveryParams{
+		WebhookID: hook.ID,
+		EventID: eventID,
+		EventType: webhookTest,
+		Payload: payload,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't send test event", err)
+		return
+	}
+
+	// The outcome is recorded on the delivery, which is what we return.
+	cfg.webhooks.send(r.Context(), delivery, hook.Url, hook.Secret, 0)
+
+	delivery, err = cfg.db.GetWebhookDelivery(r.Context(), delivery.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get delivery", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, webhookDeliveryFromDB(delivery))
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/auth"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/stream"
+)
+
+const (
+	wsMaxConnectionsPerUser = 5
+	wsMaxChannels = 20
+	wsReadLimit = 4096
+	wsOutboxSize = 16
+	wsWriteTimeout = 10 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+// Browsers can't set headers on the upgrade request, so they pass the access
+// token as a subprotocol named "bearer.<token>" alongside wsProtocol. The
+// server selects wsProtocol, so the token is never echoed back.
+const (
+	wsProtocol = "chirpy"
+	wsBearerProtocolPrefix = "bearer."
+)
+
+// wsBearerToken returns the access token from the Authorization header or,
+// failing that, from a bearer subprotocol.
+func wsBearerToken(r *http.Request) (string, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err == nil {
+		return token, nil
+	}
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			if token, ok := strings.CutPrefix(strings.TrimSpace(protocol), wsBearerProtocolPrefix); ok && token != "" {
+				return token, nil
+			}
+		}
+	}
+	return "", err
+}
+
+// WebSocket channels a client can subscribe to. Conversations are named
+// "conversation:<id>".
+const (
+	wsChannelTimeline = "timeline"
+	wsChannelNotifications = "notifications"
+	wsChannelConversationPrefix = "conversation:"
+)
+
+type wsClientMessage struct {
+	Type	string	`json:"type"`
+	Channel	string	`json:"channel"`
+}
+
+type wsServerMessage struct {
+	Type	string			`json:"type"`
+	Channel	string			`json:"channel,omitempty"`
+	Event	string			`json:"event,omitempty"`
+	ID		int64			`json:"id,omitempty"`
+	Data	json.RawMessage	`json:"data,omitempty"`
+	Error	string			`json:"error,omitempty"`
+}
+
+// wsConnections counts open WebSocket connections per user on this
+// instance.
+type wsConnections struct {
+	mu     sync.Mutex
+	byUser map[uuid.UUID]int
+}
+
+func(c *wsConnections) acquire(userID uuid.UUID) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byUser == nil {
+		c.byUser = map[uuid.UUID]int{}
+	}
+	if c.byUser[userID] >= wsMaxConnectionsPerUser {
+		return false
+	}
+	c.byUser[userID]++
+	return true
+}
+
+func(c *wsConnections) release(userID uuid.UUID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byUser[userID]--
+	if c.byUser[userID] <= 0 {
+		delete(c.byUser, userID)
+	}
+}
+
+// wsSession tracks which topics a connection is subscribed to and what it
+// may see on them.
+type wsSession struct {
+	userID			uuid.UUID
+	timelineVisible	func(stream.Event) bool
+	blocked			map[uuid.UUID]bool
+
+	mu		sync.Mutex
+	topics	map[string]string // topic -> channel name
+}
+
+// match is called by the broker for every published event.
+func(s *wsSession) match(e stream.Event) bool {
+	s.mu.Lock()
+	_, ok := s.topics[e.Topic]
+	s.mu.Unlock()
+	if !ok {
+		return false
+	}
+	switch {
+	case e.Topic == topicTimeline:
+		return s.timelineVisible(e)
+	case strings.HasPrefix(e.Topic, wsChannelConversationPrefix):
+		return !s.blocked[e.AuthorID]
+	default:
+		return true
+	}
+}
+
+func(s *wsSession) channel(topic string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.topics[topic]
+}
+
+// handlerWebSocket serves a single connection over which clients subscribe
+// to the timeline, their notifications and their conversations. Clients
+// authenticate with the same access token as the REST API, either in the
+// Authorization header or, for browsers, as a bearer subprotocol. Tokens are
+// never read from the URL, which ends up in access logs.
+func(cfg *apiConfig) handlerWebSocket(w http.ResponseWriter, r *http.Request) {
+	token, err := wsBearerToken(r)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
+		return
+	}
+	userID, err := validateJWT(r.Context(), token, cfg.secret)
+	if err != nil {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
+		return
+	}
+	if !cfg.requireNotSuspended(w, r, userID) {
+		return
+	}
+
+	if !cfg.wsConns.acquire(userID) {
+		respondWithError(w, r, http.StatusTooManyRequests, "Too many open connections", nil)
+		return
+	}
+	defer cfg.wsConns.release(userID)
+
+	timelineVisible, err := cfg.timelineFilter(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't open connection", err)
+		return
+	}
+	blocks, err := cfg.db.ListUserRelationships(r.Context(), database.ListUserRelationshipsParams{
+		UserID: userID,
+		Kind: relationshipBlock,
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't open connection", err)
+		return
+	}
+	session := &wsSession{
+		userID: userID,
+		timelineVisible: timelineVisible,
+		blocked: map[uuid.UUID]bool{},
+		topics: map[string]string{},
+	}
+	for _, block := range blocks {
+		session.blocked[block.TargetID] = true
+	}
+
+	// The server's read and write timeouts would otherwise still apply to
+	// the hijacked connection.
+	rc := http.NewResponseController(w)
+	for _, clear := range []func(time.Time) error{rc.SetReadDeadline, rc.SetWriteDeadline} {
+		if err := clear(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			respondWithError(w, r, http.StatusInternalServerError, "Couldn't open connection", err)
+			return
+		}
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols: []string{wsProtocol},
+	})
+	if err != nil {
+		// Accept has already written the response.
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(wsReadLimit)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub := cfg.stream.Subscribe(session.match)
+	defer sub.Close()
+
+	outbox := make(chan wsServerMessage, wsOutboxSize)
+	go cfg.wsReadLoop(ctx, cancel, conn, session, outbox)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		var msg wsServerMessage
+		select {
+		case <-ctx.Done():
+			conn.Close(websocket.StatusNormalClosure, "")
+			return
+		case event, ok := <-sub.Events():
+			// The broker drops subscribers that fall behind, and closes
+			// them all on shutdown.
+			if !ok {
+				conn.Close(websocket.StatusTryAgainLater, "reconnect")
+				return
+			}
+			msg = wsServerMessage{
+				Type: "event",
+				Channel: session.channel(event.Topic),
+				Event: event.Type,
+				ID: event.ID,
+				Data: event.Data,
+			}
+		case msg = <-outbox:
+		case <-ping.C:
+			pingCtx, cancelPing := context.WithTimeout(ctx, wsWriteTimeout)
+			err := conn.Ping(pingCtx)
+			cancelPing()
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		writeCtx, cancelWrite := context.WithTimeout(ctx, wsWriteTimeout)
+		err := wsjson.Write(writeCtx, conn, msg)
+		cancelWrite()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// wsReadLoop handles client messages until the connection fails, then
+// cancels the connection's context. Replies go through outbox so that only
+// the main loop writes to conn.
+func(cfg *apiConfig) wsReadLoop(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, session *wsSession, outbox chan<- wsServerMessage) {
+	defer cancel()
+
+	reply := func(msg wsServerMessage) bool {
+		select {
+		case outbox <- msg:
+			return true
+		default:
+			// The client is sending faster than it reads its replies.
+			conn.Close(websocket.StatusPolicyViolation, "too many requests")
+			return false
+		}
+	}
+
+	for {
+		var msg wsClientMessage
+		if err := wsjson.Read(ctx, conn, &msg); err != nil {
+			return
+		}
+
+		var response wsServerMessage
+		switch msg.Type {
+		case "subscribe":
+			response = cfg.wsSubscribe(ctx, session, msg.Channel)
+		case "unsubscribe":
+			response = session.unsubscribe(msg.Channel)
+		case "ping":
+			response = wsServerMessage{Type: "pong"}
+		default:
+			response = wsServerMessage{Type: "error", Error: "unknown message type"}
+		}
+		if !reply(response) {
+			return
+		}
+	}
+}
+
+func(cfg *apiConfig) wsSubscribe(ctx context.Context, session *wsSession, channel string) wsServerMessage {
+	topic, err := cfg.wsTopic(ctx, session.userID, channel)
+	if err != nil {
+		return wsServerMessage{Type: "error", Channel: channel, Error: err.Error()}
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if _, ok := session.topics[topic]; !ok && len(session.topics) >= wsMaxChannels {
+		return wsServerMessage{Type: "error", Channel: channel, Error: "too many channels"}
+	}
+	session.topics[topic] = channel
+	return wsServerMessage{Type: "subscribed", Channel: channel}
+}
+
+func(s *wsSession) unsubscribe(channel string) wsServerMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for topic, name := range s.topics {
+		if name == channel {
+			delete(s.topics, topic)
+		}
+	}
+	return wsServerMessage{Type: "unsubscribed", Channel: channel}
+}
+
+// wsTopic maps a channel name to its broker topic, checking that userID may
+// subscribe to it.
+func(cfg *apiConfig) wsTopic(ctx context.Context, userID uuid.UUID, channel string) (string, error) {
+	switch {
+	case channel == wsChannelTimeline:
+		return topicTimeline, nil
+	case channel == wsChannelNotifications:
+		return notificationsTopic(userID), nil
+	case strings.HasPrefix(channel, wsChannelConversationPrefix):
+		id, err := uuid.Parse(strings.TrimPrefix(channel, wsChannelConversationPrefix))
+		if err != nil {
+			return "", errors.New("invalid conversation ID")
+		}
+		if _, err := cfg.conversationMembers(ctx, id, userID); err != nil {
+			if errors.Is(err, errNotMember) {
+				return "", errors.New("conversation not found")
+			}
+			return "", errors.New("couldn't get conversation")
+		}
+		return conversationTopic(id), nil
+	default:
+		return "", errors.New("unknown channel")
+	}
+}
@@ -21,12 +21,44 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return argon2id.ComparePasswordAndHash(password, hash)
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
-	claim := jwt.RegisteredClaims{
-		Issuer: "chirpy",
-		Subject: userID.String(),
-		IssuedAt: jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(3600) * time.Second)),
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// HasRole reports whether role grants at least the access of required.
+// Admins can do everything moderators can, and moderators everything users can.
+func HasRole(role, required string) bool {
+	rank, ok := roleRank[role]
+	return ok && rank >= roleRank[required]
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+type Claims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+func MakeJWT(userID uuid.UUID, role string, tokenSecret string) (string, error) {
+	claim := Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer: "chirpy",
+			Subject: userID.String(),
+			IssuedAt: jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(time.Duration(3600) * time.Second)),
+		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claim)
@@ -39,27 +71,38 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
+	userID, _, err := ValidateJWTWithRole(tokenString, tokenSecret)
+	return userID, err
+}
+
+// ValidateJWTWithRole is ValidateJWT that also returns the role claim. Tokens
+// issued before roles existed carry no role and are treated as RoleUser.
+func ValidateJWTWithRole(tokenString, tokenSecret string) (uuid.UUID, string, error) {
 
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (any, error) {
 		return []byte(tokenSecret), nil
 	})
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, "", err
 	}
 
 	if !token.Valid {
-		return uuid.Nil, fmt.Errorf("token invalid or expired")
+		return uuid.Nil, "", fmt.Errorf("token invalid or expired")
 	}
 
-	if claim, ok := token.Claims.(*jwt.RegisteredClaims); ok {
+	if claim, ok := token.Claims.(*Claims); ok {
 		id, err := uuid.Parse(claim.Subject)
 		if err != nil {
-			return uuid.Nil, err
+			return uuid.Nil, "", err
+		}
+		role := claim.Role
+		if role == "" {
+			role = RoleUser
 		}
-		return id, nil
+		return id, role, nil
 	}
 	
-	return uuid.Nil, fmt.Errorf("Unknown claims type")
+	return uuid.Nil, "", fmt.Errorf("Unknown claims type")
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
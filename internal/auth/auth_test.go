@@ -79,12 +79,12 @@ func TestValidateJWT(t *testing.T) {
 	userID2, _ := uuid.NewUUID()
 
 	// Create tokens
-	JWT1, err := MakeJWT(userID1, secret)
+	JWT1, err := MakeJWT(userID1, RoleUser, secret)
 	if err != nil {
 		t.Fatalf("failed to make JWT1: %v", err)
 	}
 
-	JWT2, err := MakeJWT(userID2, secret)
+	JWT2, err := MakeJWT(userID2, RoleUser, secret)
 	if err != nil {
 		t.Fatalf("failed to make JWT2: %v", err)
 	}
@@ -201,3 +201,47 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateJWTWithRole(t *testing.T) {
+	secret := "fufufuufufuff9"
+	userID := uuid.New()
+
+	token, err := MakeJWT(userID, RoleModerator, secret)
+	if err != nil {
+		t.Fatalf("failed to make JWT: %v", err)
+	}
+
+	id, role, err := ValidateJWTWithRole(token, secret)
+	if err != nil {
+		t.Fatalf("ValidateJWTWithRole() error = %v", err)
+	}
+	if id != userID {
+		t.Errorf("ValidateJWTWithRole() returned id = %v, expected %v", id, userID)
+	}
+	if role != RoleModerator {
+		t.Errorf("ValidateJWTWithRole() returned role = %q, expected %q", role, RoleModerator)
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		role     string
+		required string
+		want     bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleModerator, true},
+		{RoleModerator, RoleModerator, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleUser, RoleModerator, false},
+		{RoleUser, RoleUser, true},
+		{"superuser", RoleUser, false},
+		{"", RoleUser, false},
+	}
+
+	for _, tt := range tests {
+		if got := HasRole(tt.role, tt.required); got != tt.want {
+			t.Errorf("HasRole(%q, %q) = %v, expected %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
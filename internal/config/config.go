@@ -0,0 +1,297 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const MinSecretLength = 32
+
+var validPlatforms = []string{"dev", "prod"}
+
+type Config struct {
+	Port           int
+	FileRoot       string
+	DBURL          string
+	Platform       string
+	JWTSecret      string
+	PolkaKey       string
+	LogFormat      string
+	LogLevel       string
+	TracesExporter string
+	AutoMigrate    bool
+	FilterFile     string
+	JobWorkers     int
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	DrainDelay        time.Duration
+	ShutdownTimeout   time.Duration
+
+	FilterReloadInterval  time.Duration
+	RefreshTokenRetention time.Duration
+}
+
+// setting describes one configuration value. Its environment variable is
+// name; in the config file the same value is keyed by name in lower case.
+type setting struct {
+	name     string
+	fallback string
+	secret   bool
+	required bool
+	apply    func(c *Config, value string) error
+}
+
+func stringSetting(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func durationSetting(field func(c *Config) *time.Duration) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+func intSetting(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func boolSetting(field func(c *Config) *bool) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+var settings = []setting{
+	{name: "PORT", fallback: "8080", apply: func(c *Config, value string) error {
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("must be a port number")
+		}
+		c.Port = port
+		return nil
+	}},
+	{name: "FILE_ROOT", fallback: ".", apply: stringSetting(func(c *Config) *string { return &c.FileRoot })},
+	{name: "DB_URL", secret: true, required: true, apply: stringSetting(func(c *Config) *string { return &c.DBURL })},
+	{name: "PLATFORM", fallback: "prod", apply: stringSetting(func(c *Config) *string { return &c.Platform })},
+	{name: "JWT_SECRET", secret: true, required: true, apply: stringSetting(func(c *Config) *string { return &c.JWTSecret })},
+	{name: "POLKA_KEY", secret: true, required: true, apply: stringSetting(func(c *Config) *string { return &c.PolkaKey })},
+	{name: "LOG_FORMAT", fallback: "json", apply: stringSetting(func(c *Config) *string { return &c.LogFormat })},
+	{name: "LOG_LEVEL", fallback: "info", apply: stringSetting(func(c *Config) *string { return &c.LogLevel })},
+	{name: "OTEL_TRACES_EXPORTER", fallback: "none", apply: stringSetting(func(c *Config) *string { return &c.TracesExporter })},
+	{name: "AUTO_MIGRATE", fallback: "false", apply: boolSetting(func(c *Config) *bool { return &c.AutoMigrate })},
+	{name: "READ_TIMEOUT", fallback: "10s", apply: durationSetting(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{name: "READ_HEADER_TIMEOUT", fallback: "5s", apply: durationSetting(func(c *Config) *time.Duration { return &c.ReadHeaderTimeout })},
+	{name: "WRITE_TIMEOUT", fallback: "30s", apply: durationSetting(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{name: "IDLE_TIMEOUT", fallback: "120s", apply: durationSetting(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{name: "DRAIN_DELAY", fallback: "5s", apply: durationSetting(func(c *Config) *time.Duration { return &c.DrainDelay })},
+	{name: "SHUTDOWN_TIMEOUT", fallback: "30s", apply: durationSetting(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{name: "FILTER_FILE", apply: stringSetting(func(c *Config) *string { return &c.FilterFile })},
+	{name: "FILTER_RELOAD_INTERVAL", fallback: "30s", apply: durationSetting(func(c *Config) *time.Duration { return &c.FilterReloadInterval })},
+	{name: "REFRESH_TOKEN_RETENTION", fallback: "168h", apply: durationSetting(func(c *Config) *time.Duration { return &c.RefreshTokenRetention })},
+	{name: "JOB_WORKERS", fallback: "4", apply: intSetting(func(c *Config) *int { return &c.JobWorkers })},
+}
+
+// Load builds the configuration from, in order of precedence: process
+// environment, a .env file in the working directory, the YAML file named by
+// CONFIG_FILE, and built-in defaults. Secrets may instead be given as a path
+// in <NAME>_FILE, whose contents are used as the value.
+func Load() (*Config, error) {
+	// godotenv never overrides variables that are already set, which gives
+	// the real environment precedence over .env.
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("loading .env: %w", err)
+	}
+	return load(os.LookupEnv)
+}
+
+func load(lookupEnv func(string) (string, bool)) (*Config, error) {
+	file := map[string]string{}
+	if path, ok := lookupEnv("CONFIG_FILE"); ok && path != "" {
+		var err error
+		file, err = readFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	lookupFile := func(name string) (string, bool) {
+		value, ok := file[strings.ToLower(name)]
+		return value, ok
+	}
+
+	cfg := &Config{}
+	for _, s := range settings {
+		value, err := resolve(s, lookupEnv, lookupFile)
+		if err != nil {
+			return nil, err
+		}
+		if value == "" && s.required {
+			return nil, fmt.Errorf("%s must be set", s.name)
+		}
+		if err := s.apply(cfg, value); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", s.name, value, err)
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// resolve returns the highest-precedence value for s. Within a source, a
+// direct value wins over a _FILE reference.
+func resolve(s setting, sources ...func(string) (string, bool)) (string, error) {
+	for _, lookup := range sources {
+		if value, ok := lookup(s.name); ok && value != "" {
+			return value, nil
+		}
+		if !s.secret {
+			continue
+		}
+		if path, ok := lookup(s.name + "_FILE"); ok && path != "" {
+			dat, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("reading %s_FILE: %w", s.name, err)
+			}
+			return strings.TrimSpace(string(dat)), nil
+		}
+	}
+	return s.fallback, nil
+}
+
+func readFile(path string) (map[string]string, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	raw := map[string]any{}
+	if err := yaml.Unmarshal(dat, &raw); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		values[strings.ToLower(key)] = fmt.Sprint(value)
+	}
+	return values, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.JWTSecret) < MinSecretLength {
+		return fmt.Errorf("JWT_SECRET must be at least %d bytes", MinSecretLength)
+	}
+	if !contains(validPlatforms, c.Platform) {
+		return fmt.Errorf("PLATFORM must be one of %s", strings.Join(validPlatforms, ", "))
+	}
+	if !contains([]string{"json", "text"}, c.LogFormat) {
+		return fmt.Errorf("LOG_FORMAT must be json or text")
+	}
+	if !contains([]string{"otlp", "stdout", "none"}, c.TracesExporter) {
+		return fmt.Errorf("OTEL_TRACES_EXPORTER must be otlp, stdout or none")
+	}
+	if c.FilterReloadInterval <= 0 {
+		return fmt.Errorf("FILTER_RELOAD_INTERVAL must be positive")
+	}
+	if c.RefreshTokenRetention < 0 {
+		return fmt.Errorf("REFRESH_TOKEN_RETENTION must not be negative")
+	}
+	if c.JobWorkers < 1 {
+		return fmt.Errorf("JOB_WORKERS must be at least 1")
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Config) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+// LogValue renders the effective configuration with secrets redacted, so the
+// whole Config can be passed to slog.
+func (c *Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("port", c.Port),
+		slog.String("file_root", c.FileRoot),
+		slog.String("db_url", redactURL(c.DBURL)),
+		slog.String("platform", c.Platform),
+		slog.String("jwt_secret", redact(c.JWTSecret)),
+		slog.String("polka_key", redact(c.PolkaKey)),
+		slog.String("log_format", c.LogFormat),
+		slog.String("log_level", c.LogLevel),
+		slog.String("otel_traces_exporter", c.TracesExporter),
+		slog.Bool("auto_migrate", c.AutoMigrate),
+		slog.String("filter_file", c.FilterFile),
+		slog.Duration("filter_reload_interval", c.FilterReloadInterval),
+		slog.Duration("refresh_token_retention", c.RefreshTokenRetention),
+		slog.Int("job_workers", c.JobWorkers),
+		slog.Duration("read_timeout", c.ReadTimeout),
+		slog.Duration("read_header_timeout", c.ReadHeaderTimeout),
+		slog.Duration("write_timeout", c.WriteTimeout),
+		slog.Duration("idle_timeout", c.IdleTimeout),
+		slog.Duration("drain_delay", c.DrainDelay),
+		slog.Duration("shutdown_timeout", c.ShutdownTimeout),
+	)
+}
+
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+// redactURL hides the password in a database URL, whether it is in the
+// userinfo or a query parameter, which is dropped. A keyword/value DSN such as
+// "host=localhost password=secret" is redacted entirely.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return redact(raw)
+	}
+	query := u.Query()
+	for _, key := range []string{"password", "sslpassword"} {
+		if query.Has(key) {
+			query.Del(key)
+			u.RawQuery = query.Encode()
+		}
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,110 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: audit_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const createAuditEvent = `-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (action, actor_id, target_type, target_id, ip, user_agent, metadata)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+`
+
+type CreateAuditEventParams struct {
+	Action     string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Ip         string
+	UserAgent  string
+	Metadata   json.RawMessage
+}
+
+func (q *Queries) CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) error {
+	_, err := q.db.ExecContext(ctx, createAuditEvent,
+		arg.Action,
+		arg.ActorID,
+		arg.TargetType,
+		arg.TargetID,
+		arg.Ip,
+		arg.UserAgent,
+		arg.Metadata,
+	)
+	return err
+}
+
+const listAuditEvents = `-- name: ListAuditEvents :many
+SELECT id, created_at, action, actor_id, target_type, target_id, ip, user_agent, metadata FROM audit_events
+WHERE ($2::uuid IS NULL OR actor_id = $2)
+AND ($3::text IS NULL OR action = $3)
+AND ($4::text IS NULL OR target_id = $4)
+AND ($5::timestamp IS NULL OR created_at >= $5)
+AND ($6::timestamp IS NULL OR created_at < $6)
+ORDER BY id DESC
+LIMIT $1
+`
+
+type ListAuditEventsParams struct {
+	Limit    int32
+	ActorID  uuid.NullUUID
+	Action   sql.NullString
+	TargetID sql.NullString
+	Since    sql.NullTime
+	Until    sql.NullTime
+}
+
+func (q *Queries) ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx, listAuditEvents,
+		arg.Limit,
+		arg.ActorID,
+		arg.Action,
+		arg.TargetID,
+		arg.Since,
+		arg.Until,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AuditEvent
+	for rows.Next() {
+		var i AuditEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Action,
+			&i.ActorID,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Ip,
+			&i.UserAgent,
+			&i.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,372 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: bookmarks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countBookmarkCollections = `-- name: CountBookmarkCollections :one
+SELECT COUNT(*) FROM bookmark_collections
+WHERE user_id = $1
+`
+
+func (q *Queries) CountBookmarkCollections(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countBookmarkCollections, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createBookmarkCollection = `-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, created_at, updated_at, user_id, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+ON CONFLICT (user_id, name) DO NOTHING
+RETURNING id, created_at, updated_at, user_id, name
+`
+
+type CreateBookmarkCollectionParams struct {
+	UserID uuid.UUID
+	Name   string
+}
+
+func (q *Queries) CreateBookmarkCollection(ctx context.Context, arg CreateBookmarkCollectionParams) (BookmarkCollection, error) {
+	row := q.db.QueryRowContext(ctx, createBookmarkCollection, arg.UserID, arg.Name)
+	var i BookmarkCollection
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+	)
+	return i, err
+}
+
+const deleteBookmark = `-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2
+`
+
+type DeleteBookmarkParams struct {
+	UserID  uuid.UUID
+	ChirpID uuid.UUID
+}
+
+func (q *Queries) DeleteBookmark(ctx context.Context, arg DeleteBookmarkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteBookmark, arg.UserID, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteBookmarkCollection = `-- name: DeleteBookmarkCollection :exec
+DELETE FROM bookmark_collections
+WHERE id = $1
+`
+
+func (q *Queries) DeleteBookmarkCollection(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteBookmarkCollection, id)
+	return err
+}
+
+const exportBookmarksByUser = `-- name: ExportBookmarksByUser :many
+SELECT user_id, chirp_id, collection_id, created_at FROM bookmarks
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ExportBookmarksByUser(ctx context.Context, userID uuid.UUID) ([]Bookmark, error) {
+	rows, err := q.db.QueryContext(ctx, exportBookmarksByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Bookmark
+	for rows.Next() {
+		var i Bookmark
+		if err := rows.Scan(
+			&i.UserID,
+			&i.ChirpID,
+			&i.CollectionID,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getBookmarkCollection = `-- name: GetBookmarkCollection :one
+SELECT id, created_at, updated_at, user_id, name FROM bookmark_collections
+WHERE id = $1
+`
+
+func (q *Queries) GetBookmarkCollection(ctx context.Context, id uuid.UUID) (BookmarkCollection, error) {
+	row := q.db.QueryRowContext(ctx, getBookmarkCollection, id)
+	var i BookmarkCollection
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+	)
+	return i, err
+}
+
+const getBookmarkedChirpIDs = `-- name: GetBookmarkedChirpIDs :many
+SELECT chirp_id FROM bookmarks
+WHERE user_id = $1
+AND chirp_id = ANY($2::uuid[])
+`
+
+type GetBookmarkedChirpIDsParams struct {
+	UserID   uuid.UUID
+	ChirpIds []uuid.UUID
+}
+
+// The chirps among chirp_ids that the user has bookmarked.
+func (q *Queries) GetBookmarkedChirpIDs(ctx context.Context, arg GetBookmarkedChirpIDsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getBookmarkedChirpIDs, arg.UserID, pq.Array(arg.ChirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var chirp_id uuid.UUID
+		if err := rows.Scan(&chirp_id); err != nil {
+			return nil, err
+		}
+		items = append(items, chirp_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBookmarkCollections = `-- name: ListBookmarkCollections :many
+SELECT
+    bookmark_collections.id, bookmark_collections.created_at, bookmark_collections.updated_at, bookmark_collections.user_id, bookmark_collections.name,
+    (
+        SELECT COUNT(*) FROM bookmarks
+        WHERE bookmarks.collection_id = bookmark_collections.id
+    ) AS bookmark_count
+FROM bookmark_collections
+WHERE bookmark_collections.user_id = $1
+ORDER BY bookmark_collections.name
+`
+
+type ListBookmarkCollectionsRow struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	UserID        uuid.UUID
+	Name          string
+	BookmarkCount int64
+}
+
+func (q *Queries) ListBookmarkCollections(ctx context.Context, userID uuid.UUID) ([]ListBookmarkCollectionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBookmarkCollections, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBookmarkCollectionsRow
+	for rows.Next() {
+		var i ListBookmarkCollectionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Name,
+			&i.BookmarkCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listBookmarks = `-- name: ListBookmarks :many
+SELECT
+    bookmarks.collection_id,
+    bookmarks.created_at AS bookmarked_at,
+    chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.hidden_at
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = $1
+AND chirps.hidden_at IS NULL
+AND (
+    $2::uuid IS NULL
+    OR bookmarks.collection_id = $2::uuid
+)
+AND (
+    $3::timestamp IS NULL
+    OR (bookmarks.created_at, bookmarks.chirp_id) < (
+        $3::timestamp,
+        $4::uuid
+    )
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND (
+        (user_id = chirps.user_id AND target_id = $1)
+        OR (user_id = $1 AND target_id = chirps.user_id)
+    )
+)
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT $5
+`
+
+type ListBookmarksParams struct {
+	UserID          uuid.UUID
+	CollectionID    uuid.NullUUID
+	BeforeCreatedAt sql.NullTime
+	BeforeChirpID   uuid.NullUUID
+	Limit           int32
+}
+
+type ListBookmarksRow struct {
+	CollectionID uuid.NullUUID
+	BookmarkedAt time.Time
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	HiddenAt     sql.NullTime
+}
+
+// Bookmarked chirps the user can still see, most recently bookmarked first.
+func (q *Queries) ListBookmarks(ctx context.Context, arg ListBookmarksParams) ([]ListBookmarksRow, error) {
+	rows, err := q.db.QueryContext(ctx, listBookmarks,
+		arg.UserID,
+		arg.CollectionID,
+		arg.BeforeCreatedAt,
+		arg.BeforeChirpID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListBookmarksRow
+	for rows.Next() {
+		var i ListBookmarksRow
+		if err := rows.Scan(
+			&i.CollectionID,
+			&i.BookmarkedAt,
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const renameBookmarkCollection = `-- name: RenameBookmarkCollection :one
+UPDATE bookmark_collections
+SET name = $2, updated_at = NOW()
+WHERE bookmark_collections.id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM bookmark_collections other
+    WHERE other.user_id = bookmark_collections.user_id
+    AND other.name = $2
+    AND other.id <> $1
+)
+RETURNING id, created_at, updated_at, user_id, name
+`
+
+type RenameBookmarkCollectionParams struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func (q *Queries) RenameBookmarkCollection(ctx context.Context, arg RenameBookmarkCollectionParams) (BookmarkCollection, error) {
+	row := q.db.QueryRowContext(ctx, renameBookmarkCollection, arg.ID, arg.Name)
+	var i BookmarkCollection
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Name,
+	)
+	return i, err
+}
+
+const upsertBookmark = `-- name: UpsertBookmark :one
+INSERT INTO bookmarks (user_id, chirp_id, collection_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, chirp_id) DO UPDATE
+SET collection_id = EXCLUDED.collection_id
+RETURNING user_id, chirp_id, collection_id, created_at
+`
+
+type UpsertBookmarkParams struct {
+	UserID       uuid.UUID
+	ChirpID      uuid.UUID
+	CollectionID uuid.NullUUID
+}
+
+// Bookmarking a chirp again moves it to the given collection.
+func (q *Queries) UpsertBookmark(ctx context.Context, arg UpsertBookmarkParams) (Bookmark, error) {
+	row := q.db.QueryRowContext(ctx, upsertBookmark, arg.UserID, arg.ChirpID, arg.CollectionID)
+	var i Bookmark
+	err := row.Scan(
+		&i.UserID,
+		&i.ChirpID,
+		&i.CollectionID,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,167 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: chirp_events.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const deleteChirpEventsOlderThan = `-- name: DeleteChirpEventsOlderThan :execrows
+DELETE FROM chirp_events
+WHERE created_at < NOW() - make_interval(secs => $1::double precision)
+`
+
+func (q *Queries) DeleteChirpEventsOlderThan(ctx context.Context, ageSeconds float64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpEventsOlderThan, ageSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getChirpEventTxHorizon = `-- name: GetChirpEventTxHorizon :one
+SELECT tx_horizon FROM chirp_events
+WHERE id = $1
+`
+
+func (q *Queries) GetChirpEventTxHorizon(ctx context.Context, id int64) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getChirpEventTxHorizon, id)
+	var tx_horizon int64
+	err := row.Scan(&tx_horizon)
+	return tx_horizon, err
+}
+
+const getTxHorizon = `-- name: GetTxHorizon :one
+SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint AS tx_horizon
+`
+
+// The oldest transaction still running. Any chirp event not visible yet
+// was written by this transaction or a later one.
+func (q *Queries) GetTxHorizon(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getTxHorizon)
+	var tx_horizon int64
+	err := row.Scan(&tx_horizon)
+	return tx_horizon, err
+}
+
+const listChirpEvents = `-- name: ListChirpEvents :many
+SELECT
+    chirp_events.id,
+    chirp_events.tx_id,
+    chirp_events.created_at,
+    chirp_events.type,
+    chirp_events.chirp_id,
+    chirp_events.user_id,
+    chirps.created_at AS chirp_created_at,
+    chirps.updated_at AS chirp_updated_at,
+    chirps.body AS chirp_body,
+    COALESCE(
+        users.status = 'limited' AND (users.status_until IS NULL OR users.status_until > NOW()),
+        false
+    )::boolean AS author_limited
+FROM chirp_events
+LEFT JOIN chirps ON chirps.id = chirp_events.chirp_id AND chirps.hidden_at IS NULL
+LEFT JOIN users ON users.id = chirp_events.user_id
+WHERE chirp_events.id > $1
+AND chirp_events.tx_id >= $2
+ORDER BY chirp_events.id ASC
+LIMIT $3
+`
+
+type ListChirpEventsParams struct {
+	AfterID int64
+	MinTxID int64
+	Limit   int32
+}
+
+type ListChirpEventsRow struct {
+	ID             int64
+	TxID           int64
+	CreatedAt      time.Time
+	Type           string
+	ChirpID        uuid.UUID
+	UserID         uuid.UUID
+	ChirpCreatedAt sql.NullTime
+	ChirpUpdatedAt sql.NullTime
+	ChirpBody      sql.NullString
+	AuthorLimited  bool
+}
+
+// Events written by transaction min_tx_id or later, in id order from
+// after_id. author_limited is set while the author is limited, so that
+// streams can hide their chirps from everyone else as GetAllChirps does.
+func (q *Queries) ListChirpEvents(ctx context.Context, arg ListChirpEventsParams) ([]ListChirpEventsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpEvents, arg.AfterID, arg.MinTxID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListChirpEventsRow
+	for rows.Next() {
+		var i ListChirpEventsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.TxID,
+			&i.CreatedAt,
+			&i.Type,
+			&i.ChirpID,
+			&i.UserID,
+			&i.ChirpCreatedAt,
+			&i.ChirpUpdatedAt,
+			&i.ChirpBody,
+			&i.AuthorLimited,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listStreamHiddenAuthors = `-- name: ListStreamHiddenAuthors :many
+SELECT target_id AS user_id FROM user_relationships
+WHERE user_relationships.user_id = $1
+AND kind IN ('block', 'mute')
+UNION
+SELECT user_relationships.user_id FROM user_relationships
+WHERE target_id = $1
+AND kind = 'block'
+`
+
+// Users whose chirps viewer_id shouldn't see live: anyone they've blocked or
+// muted, and anyone who has blocked them.
+func (q *Queries) ListStreamHiddenAuthors(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, listStreamHiddenAuthors, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
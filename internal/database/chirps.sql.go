@@ -20,7 +20,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at
 `
 
 type CreateChirpParams struct {
@@ -37,6 +37,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
 	)
 	return i, err
 }
@@ -51,13 +52,62 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+const exportChirpsByUser = `-- name: ExportChirpsByUser :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at FROM chirps
+WHERE user_id = $1
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+// Every chirp a user has posted, including hidden ones, for export-user.
+func (q *Queries) ExportChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, exportChirpsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllChirps = `-- name: GetAllChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.hidden_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.hidden_at IS NULL
+AND (
+    users.status <> 'limited'
+    OR users.status_until <= NOW()
+    OR users.id = $1
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE (kind = 'block' AND user_id = chirps.user_id AND target_id = $1)
+    OR (kind IN ('block', 'mute') AND user_id = $1 AND target_id = chirps.user_id)
+)
+ORDER BY chirps.created_at ASC
+`
+
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +121,7 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -86,13 +137,30 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getAllChirpsByUser = `-- name: GetAllChirpsByUser :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-WHERE user_id = $1
-ORDER BY created_at ASC
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.hidden_at FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1
+AND chirps.hidden_at IS NULL
+AND (
+    users.status <> 'limited'
+    OR users.status_until <= NOW()
+    OR users.id = $2
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE (kind = 'block' AND user_id = chirps.user_id AND target_id = $2)
+    OR (kind IN ('block', 'mute') AND user_id = $2 AND target_id = chirps.user_id)
+)
+ORDER BY chirps.created_at ASC
 `
 
-func (q *Queries) GetAllChirpsByUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirpsByUser, userID)
+type GetAllChirpsByUserParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.NullUUID
+}
+
+func (q *Queries) GetAllChirpsByUser(ctx context.Context, arg GetAllChirpsByUserParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirpsByUser, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -106,6 +174,7 @@ func (q *Queries) GetAllChirpsByUser(ctx context.Context, userID uuid.UUID) ([]C
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
 		); err != nil {
 			return nil, err
 		}
@@ -121,7 +190,7 @@ func (q *Queries) GetAllChirpsByUser(ctx context.Context, userID uuid.UUID) ([]C
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, hidden_at FROM chirps
 WHERE id = $1
 `
 
@@ -134,6 +203,18 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
 	)
 	return i, err
 }
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(), updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
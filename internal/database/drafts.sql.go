@@ -0,0 +1,163 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: drafts.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const countDrafts = `-- name: CountDrafts :one
+SELECT COUNT(*) FROM drafts
+WHERE user_id = $1
+`
+
+func (q *Queries) CountDrafts(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countDrafts, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createDraft = `-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING id, created_at, updated_at, user_id, body
+`
+
+type CreateDraftParams struct {
+	UserID uuid.UUID
+	Body   string
+}
+
+func (q *Queries) CreateDraft(ctx context.Context, arg CreateDraftParams) (Draft, error) {
+	row := q.db.QueryRowContext(ctx, createDraft, arg.UserID, arg.Body)
+	var i Draft
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const deleteDraft = `-- name: DeleteDraft :exec
+DELETE FROM drafts
+WHERE id = $1
+`
+
+func (q *Queries) DeleteDraft(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteDraft, id)
+	return err
+}
+
+const getDraft = `-- name: GetDraft :one
+SELECT id, created_at, updated_at, user_id, body FROM drafts
+WHERE id = $1
+`
+
+func (q *Queries) GetDraft(ctx context.Context, id uuid.UUID) (Draft, error) {
+	row := q.db.QueryRowContext(ctx, getDraft, id)
+	var i Draft
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const listDrafts = `-- name: ListDrafts :many
+SELECT id, created_at, updated_at, user_id, body FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC
+`
+
+func (q *Queries) ListDrafts(ctx context.Context, userID uuid.UUID) ([]Draft, error) {
+	rows, err := q.db.QueryContext(ctx, listDrafts, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Draft
+	for rows.Next() {
+		var i Draft
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockDraft = `-- name: LockDraft :one
+SELECT id, created_at, updated_at, user_id, body FROM drafts
+WHERE id = $1
+FOR UPDATE
+`
+
+// Locks the draft until the end of the transaction, so it can only be
+// published once.
+func (q *Queries) LockDraft(ctx context.Context, id uuid.UUID) (Draft, error) {
+	row := q.db.QueryRowContext(ctx, lockDraft, id)
+	var i Draft
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const updateDraft = `-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, user_id, body
+`
+
+type UpdateDraftParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateDraft(ctx context.Context, arg UpdateDraftParams) (Draft, error) {
+	row := q.db.QueryRowContext(ctx, updateDraft, arg.ID, arg.Body)
+	var i Draft
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+	)
+	return i, err
+}
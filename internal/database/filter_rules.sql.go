@@ -0,0 +1,92 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: filter_rules.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const deleteFilterRule = `-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE word = $1
+`
+
+func (q *Queries) DeleteFilterRule(ctx context.Context, word string) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFilterRule, word)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const listFilterRules = `-- name: ListFilterRules :many
+SELECT word, action, created_at, updated_at, created_by FROM filter_rules
+ORDER BY word ASC
+`
+
+func (q *Queries) ListFilterRules(ctx context.Context) ([]FilterRule, error) {
+	rows, err := q.db.QueryContext(ctx, listFilterRules)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []FilterRule
+	for rows.Next() {
+		var i FilterRule
+		if err := rows.Scan(
+			&i.Word,
+			&i.Action,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.CreatedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertFilterRule = `-- name: UpsertFilterRule :one
+INSERT INTO filter_rules (word, action, created_at, updated_at, created_by)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW(),
+    $3
+)
+ON CONFLICT (word) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING word, action, created_at, updated_at, created_by
+`
+
+type UpsertFilterRuleParams struct {
+	Word      string
+	Action    string
+	CreatedBy uuid.NullUUID
+}
+
+func (q *Queries) UpsertFilterRule(ctx context.Context, arg UpsertFilterRuleParams) (FilterRule, error) {
+	row := q.db.QueryRowContext(ctx, upsertFilterRule, arg.Word, arg.Action, arg.CreatedBy)
+	var i FilterRule
+	err := row.Scan(
+		&i.Word,
+		&i.Action,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
@@ -0,0 +1,355 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: jobs.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const buryJob = `-- name: BuryJob :execrows
+UPDATE jobs
+SET status = 'dead', last_error = $1, finished_at = NOW(), updated_at = NOW()
+WHERE id = $2 AND status = 'running' AND attempts = $3
+`
+
+type BuryJobParams struct {
+	LastError sql.NullString
+	ID        uuid.UUID
+	Attempt   int32
+}
+
+func (q *Queries) BuryJob(ctx context.Context, arg BuryJobParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, buryJob, arg.LastError, arg.ID, arg.Attempt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const claimJobs = `-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    run_at = NOW() + make_interval(secs => $1::double precision),
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE status IN ('pending', 'running')
+    AND run_at <= NOW()
+    AND kind = ANY($2::text[])
+    ORDER BY run_at ASC
+    LIMIT $3
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, payload, attempts, max_attempts
+`
+
+type ClaimJobsParams struct {
+	LeaseSeconds float64
+	Kinds        []string
+	BatchSize    int32
+}
+
+type ClaimJobsRow struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     json.RawMessage
+	Attempts    int32
+	MaxAttempts int32
+}
+
+// Leases due jobs by marking them running until the lease runs out. A
+// running job whose lease has run out was abandoned and is due again.
+func (q *Queries) ClaimJobs(ctx context.Context, arg ClaimJobsParams) ([]ClaimJobsRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimJobs, arg.LeaseSeconds, pq.Array(arg.Kinds), arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimJobsRow
+	for rows.Next() {
+		var i ClaimJobsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Kind,
+			&i.Payload,
+			&i.Attempts,
+			&i.MaxAttempts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const completeJob = `-- name: CompleteJob :execrows
+UPDATE jobs
+SET status = 'succeeded', finished_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status = 'running' AND attempts = $2
+`
+
+type CompleteJobParams struct {
+	ID      uuid.UUID
+	Attempt int32
+}
+
+// The result updates, retry and bury below only apply to the attempt that
+// holds the lease, so a worker that outlived its lease can't overwrite the
+// job after it has been claimed again.
+func (q *Queries) CompleteJob(ctx context.Context, arg CompleteJobParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, completeJob, arg.ID, arg.Attempt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countJobsByKindAndStatus = `-- name: CountJobsByKindAndStatus :many
+SELECT kind, status, COUNT(*) AS count FROM jobs
+GROUP BY kind, status
+ORDER BY kind, status
+`
+
+type CountJobsByKindAndStatusRow struct {
+	Kind   string
+	Status string
+	Count  int64
+}
+
+func (q *Queries) CountJobsByKindAndStatus(ctx context.Context) ([]CountJobsByKindAndStatusRow, error) {
+	rows, err := q.db.QueryContext(ctx, countJobsByKindAndStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CountJobsByKindAndStatusRow
+	for rows.Next() {
+		var i CountJobsByKindAndStatusRow
+		if err := rows.Scan(&i.Kind, &i.Status, &i.Count); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const deleteFinishedJobsOlderThan = `-- name: DeleteFinishedJobsOlderThan :execrows
+DELETE FROM jobs
+WHERE (status = 'succeeded' AND finished_at < NOW() - make_interval(secs => $1::double precision))
+OR (status = 'dead' AND finished_at < NOW() - make_interval(secs => $2::double precision))
+`
+
+type DeleteFinishedJobsOlderThanParams struct {
+	SucceededAgeSeconds float64
+	DeadAgeSeconds      float64
+}
+
+// Dead jobs are kept longer than succeeded ones so there is time to look
+// into them.
+func (q *Queries) DeleteFinishedJobsOlderThan(ctx context.Context, arg DeleteFinishedJobsOlderThanParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFinishedJobsOlderThan, arg.SucceededAgeSeconds, arg.DeadAgeSeconds)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteJob = `-- name: DeleteJob :execrows
+DELETE FROM jobs
+WHERE id = $1 AND status IN ('succeeded', 'dead')
+`
+
+func (q *Queries) DeleteJob(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteJob, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enqueueJob = `-- name: EnqueueJob :exec
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, max_attempts, run_at, unique_key)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    NOW() + make_interval(secs => $4::double precision),
+    $5
+)
+ON CONFLICT (unique_key) DO NOTHING
+`
+
+type EnqueueJobParams struct {
+	Kind         string
+	Payload      json.RawMessage
+	MaxAttempts  int32
+	DelaySeconds float64
+	UniqueKey    sql.NullString
+}
+
+func (q *Queries) EnqueueJob(ctx context.Context, arg EnqueueJobParams) error {
+	_, err := q.db.ExecContext(ctx, enqueueJob,
+		arg.Kind,
+		arg.Payload,
+		arg.MaxAttempts,
+		arg.DelaySeconds,
+		arg.UniqueKey,
+	)
+	return err
+}
+
+const getJob = `-- name: GetJob :one
+SELECT id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, last_error, finished_at, unique_key FROM jobs
+WHERE id = $1
+`
+
+func (q *Queries) GetJob(ctx context.Context, id uuid.UUID) (Job, error) {
+	row := q.db.QueryRowContext(ctx, getJob, id)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LastError,
+		&i.FinishedAt,
+		&i.UniqueKey,
+	)
+	return i, err
+}
+
+const listJobs = `-- name: ListJobs :many
+SELECT id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, last_error, finished_at, unique_key FROM jobs
+WHERE ($1::text IS NULL OR status = $1)
+AND ($2::text IS NULL OR kind = $2)
+ORDER BY created_at DESC
+LIMIT $3
+`
+
+type ListJobsParams struct {
+	Status sql.NullString
+	Kind   sql.NullString
+	Limit  int32
+}
+
+func (q *Queries) ListJobs(ctx context.Context, arg ListJobsParams) ([]Job, error) {
+	rows, err := q.db.QueryContext(ctx, listJobs, arg.Status, arg.Kind, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Job
+	for rows.Next() {
+		var i Job
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Kind,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.MaxAttempts,
+			&i.RunAt,
+			&i.LastError,
+			&i.FinishedAt,
+			&i.UniqueKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const requeueDeadJob = `-- name: RequeueDeadJob :one
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'dead'
+RETURNING id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, last_error, finished_at, unique_key
+`
+
+// Gives a dead job a fresh set of attempts.
+func (q *Queries) RequeueDeadJob(ctx context.Context, id uuid.UUID) (Job, error) {
+	row := q.db.QueryRowContext(ctx, requeueDeadJob, id)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Kind,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.MaxAttempts,
+		&i.RunAt,
+		&i.LastError,
+		&i.FinishedAt,
+		&i.UniqueKey,
+	)
+	return i, err
+}
+
+const retryJob = `-- name: RetryJob :execrows
+UPDATE jobs
+SET status = 'pending',
+    run_at = NOW() + make_interval(secs => $1::double precision),
+    last_error = $2,
+    updated_at = NOW()
+WHERE id = $3 AND status = 'running' AND attempts = $4
+`
+
+type RetryJobParams struct {
+	DelaySeconds float64
+	LastError    sql.NullString
+	ID           uuid.UUID
+	Attempt      int32
+}
+
+func (q *Queries) RetryJob(ctx context.Context, arg RetryJobParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, retryJob,
+		arg.DelaySeconds,
+		arg.LastError,
+		arg.ID,
+		arg.Attempt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,376 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: messages.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const addConversationMember = `-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+`
+
+type AddConversationMemberParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) AddConversationMember(ctx context.Context, arg AddConversationMemberParams) error {
+	_, err := q.db.ExecContext(ctx, addConversationMember, arg.ConversationID, arg.UserID)
+	return err
+}
+
+const countUnreadMessages = `-- name: CountUnreadMessages :one
+SELECT COUNT(*) FROM messages
+JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+WHERE conversation_members.user_id = $1
+AND messages.sender_id IS DISTINCT FROM conversation_members.user_id
+AND messages.created_at > COALESCE(conversation_members.last_read_at, '-infinity')
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND user_id = conversation_members.user_id
+    AND target_id = messages.sender_id
+)
+`
+
+func (q *Queries) CountUnreadMessages(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadMessages, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createConversation = `-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING id, created_at, updated_at, created_by
+`
+
+func (q *Queries) CreateConversation(ctx context.Context, createdBy uuid.NullUUID) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, createConversation, createdBy)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const createMessage = `-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING id, created_at, conversation_id, sender_id, body
+`
+
+type CreateMessageParams struct {
+	ConversationID uuid.UUID
+	SenderID       uuid.NullUUID
+	Body           string
+}
+
+func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, createMessage, arg.ConversationID, arg.SenderID, arg.Body)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const exportMessagesBySender = `-- name: ExportMessagesBySender :many
+SELECT id, created_at, conversation_id, sender_id, body FROM messages
+WHERE sender_id = $1
+ORDER BY created_at ASC
+`
+
+// Only the user's own messages: what others wrote to them is theirs.
+func (q *Queries) ExportMessagesBySender(ctx context.Context, senderID uuid.NullUUID) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, exportMessagesBySender, senderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Body,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const findDirectConversation = `-- name: FindDirectConversation :one
+SELECT conversations.id, conversations.created_at, conversations.updated_at, conversations.created_by FROM conversations
+JOIN conversation_members a ON a.conversation_id = conversations.id AND a.user_id = $1
+JOIN conversation_members b ON b.conversation_id = conversations.id AND b.user_id = $2
+WHERE (
+    SELECT COUNT(*) FROM conversation_members m
+    WHERE m.conversation_id = conversations.id
+) = 2
+LIMIT 1
+`
+
+type FindDirectConversationParams struct {
+	UserA uuid.UUID
+	UserB uuid.UUID
+}
+
+func (q *Queries) FindDirectConversation(ctx context.Context, arg FindDirectConversationParams) (Conversation, error) {
+	row := q.db.QueryRowContext(ctx, findDirectConversation, arg.UserA, arg.UserB)
+	var i Conversation
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.CreatedBy,
+	)
+	return i, err
+}
+
+const getConversationMembers = `-- name: GetConversationMembers :many
+SELECT user_id FROM conversation_members
+WHERE conversation_id = $1
+ORDER BY joined_at ASC, user_id ASC
+`
+
+func (q *Queries) GetConversationMembers(ctx context.Context, conversationID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getConversationMembers, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var user_id uuid.UUID
+		if err := rows.Scan(&user_id); err != nil {
+			return nil, err
+		}
+		items = append(items, user_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getMessage = `-- name: GetMessage :one
+SELECT id, created_at, conversation_id, sender_id, body FROM messages
+WHERE id = $1
+`
+
+func (q *Queries) GetMessage(ctx context.Context, id uuid.UUID) (Message, error) {
+	row := q.db.QueryRowContext(ctx, getMessage, id)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ConversationID,
+		&i.SenderID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const listConversationsForUser = `-- name: ListConversationsForUser :many
+SELECT
+    conversations.id, conversations.created_at, conversations.updated_at, conversations.created_by,
+    ARRAY(
+        SELECT m.user_id FROM conversation_members m
+        WHERE m.conversation_id = conversations.id
+        ORDER BY m.joined_at ASC, m.user_id ASC
+    )::uuid[] AS member_ids,
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+        AND messages.sender_id IS DISTINCT FROM conversation_members.user_id
+        AND messages.created_at > COALESCE(conversation_members.last_read_at, '-infinity')
+        AND NOT EXISTS (
+            SELECT 1 FROM user_relationships
+            WHERE kind = 'block'
+            AND user_id = conversation_members.user_id
+            AND target_id = messages.sender_id
+        )
+    ) AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = $1
+ORDER BY conversations.updated_at DESC
+`
+
+type ListConversationsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CreatedBy   uuid.NullUUID
+	MemberIds   []uuid.UUID
+	UnreadCount int64
+}
+
+func (q *Queries) ListConversationsForUser(ctx context.Context, userID uuid.UUID) ([]ListConversationsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, listConversationsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListConversationsForUserRow
+	for rows.Next() {
+		var i ListConversationsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.CreatedBy,
+			pq.Array(&i.MemberIds),
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessages = `-- name: ListMessages :many
+SELECT messages.id, messages.created_at, messages.conversation_id, messages.sender_id, messages.body FROM messages
+WHERE messages.conversation_id = $1
+AND (
+    $3::uuid IS NULL
+    OR (messages.created_at, messages.id) < (
+        SELECT b.created_at, b.id FROM messages b
+        WHERE b.id = $3::uuid
+    )
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND user_id = $4
+    AND target_id = messages.sender_id
+)
+ORDER BY messages.created_at DESC, messages.id DESC
+LIMIT $2
+`
+
+type ListMessagesParams struct {
+	ConversationID uuid.UUID
+	Limit          int32
+	Before         uuid.NullUUID
+	ViewerID       uuid.UUID
+}
+
+func (q *Queries) ListMessages(ctx context.Context, arg ListMessagesParams) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listMessages,
+		arg.ConversationID,
+		arg.Limit,
+		arg.Before,
+		arg.ViewerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Message
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ConversationID,
+			&i.SenderID,
+			&i.Body,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markConversationRead = `-- name: MarkConversationRead :execrows
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1
+AND user_id = $2
+`
+
+type MarkConversationReadParams struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+}
+
+func (q *Queries) MarkConversationRead(ctx context.Context, arg MarkConversationReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markConversationRead, arg.ConversationID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const touchConversation = `-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchConversation, id)
+	return err
+}
@@ -6,17 +6,147 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type AuditEvent struct {
+	ID         int64
+	CreatedAt  time.Time
+	Action     string
+	ActorID    uuid.NullUUID
+	TargetType string
+	TargetID   string
+	Ip         string
+	UserAgent  string
+	Metadata   json.RawMessage
+}
+
+type Bookmark struct {
+	UserID       uuid.UUID
+	ChirpID      uuid.UUID
+	CollectionID uuid.NullUUID
+	CreatedAt    time.Time
+}
+
+type BookmarkCollection struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
 	Body      string
 	UserID    uuid.UUID
+	HiddenAt  sql.NullTime
+}
+
+type ChirpEvent struct {
+	ID        int64
+	CreatedAt time.Time
+	Type      string
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	TxID      int64
+	TxHorizon int64
+}
+
+type Conversation struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	CreatedBy uuid.NullUUID
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+}
+
+type Draft struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+}
+
+type FilterRule struct {
+	Word      string
+	Action    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	CreatedBy uuid.NullUUID
+}
+
+type Job struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Kind        string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LastError   sql.NullString
+	FinishedAt  sql.NullTime
+	UniqueKey   sql.NullString
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.NullUUID
+	Body           string
+}
+
+type Notification struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	Type        string
+	ActorID     uuid.NullUUID
+	SubjectType string
+	SubjectID   string
+	GroupKey    string
+	ReadAt      sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Type      string
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	ClosesAt  time.Time
+}
+
+type PollOption struct {
+	ChirpID  uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Position  int32
+	CreatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,6 +158,40 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 }
 
+type Report struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	ReporterID     uuid.NullUUID
+	ReportedUserID uuid.UUID
+	ChirpID        uuid.NullUUID
+	Reason         string
+	Status         string
+	AssigneeID     uuid.NullUUID
+	Resolution     sql.NullString
+	ResolvedAt     sql.NullTime
+	ResolvedBy     uuid.NullUUID
+}
+
+type ReportNote struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ReportID  uuid.UUID
+	AuthorID  uuid.NullUUID
+	Body      string
+}
+
+type ScheduledChirp struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	Body      string
+	PublishAt time.Time
+	Status    string
+	Error     sql.NullString
+}
+
 type User struct {
 	ID             uuid.UUID
 	CreatedAt      time.Time
@@ -35,4 +199,41 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    bool
+	Role           string
+	Status         string
+	StatusReason   sql.NullString
+	StatusUntil    sql.NullTime
+}
+
+type UserRelationship struct {
+	UserID    uuid.UUID
+	TargetID  uuid.UUID
+	Kind      string
+	CreatedAt time.Time
+}
+
+type Webhook struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	OwnerID   uuid.UUID
+	Global    bool
+	Url       string
+	Secret    string
+	Events    []string
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	WebhookID      uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
 }
@@ -0,0 +1,308 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: notifications.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countUnreadNotifications = `-- name: CountUnreadNotifications :one
+SELECT COUNT(DISTINCT group_key) FROM notifications
+WHERE user_id = $1
+AND read_at IS NULL
+`
+
+func (q *Queries) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUnreadNotifications, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createNotification = `-- name: CreateNotification :execrows
+INSERT INTO notifications (id, created_at, user_id, type, actor_id, subject_type, subject_id, group_key)
+SELECT
+    gen_random_uuid(),
+    NOW(),
+    $1::uuid,
+    $2::text,
+    $3::uuid,
+    $4::text,
+    $5::text,
+    $6::text
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE notification_preferences.user_id = $1
+    AND notification_preferences.type = $2
+    AND NOT notification_preferences.enabled
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE user_relationships.kind = 'block'
+    AND user_relationships.user_id = $1
+    AND user_relationships.target_id = $3
+)
+`
+
+type CreateNotificationParams struct {
+	UserID      uuid.UUID
+	Type        string
+	ActorID     uuid.NullUUID
+	SubjectType string
+	SubjectID   string
+	GroupKey    string
+}
+
+// Skips the insert if the recipient has turned this type off or has blocked
+// the actor.
+func (q *Queries) CreateNotification(ctx context.Context, arg CreateNotificationParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createNotification,
+		arg.UserID,
+		arg.Type,
+		arg.ActorID,
+		arg.SubjectType,
+		arg.SubjectID,
+		arg.GroupKey,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const exportNotificationsByUser = `-- name: ExportNotificationsByUser :many
+SELECT id, created_at, user_id, type, actor_id, subject_type, subject_id, group_key, read_at FROM notifications
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ExportNotificationsByUser(ctx context.Context, userID uuid.UUID) ([]Notification, error) {
+	rows, err := q.db.QueryContext(ctx, exportNotificationsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Notification
+	for rows.Next() {
+		var i Notification
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UserID,
+			&i.Type,
+			&i.ActorID,
+			&i.SubjectType,
+			&i.SubjectID,
+			&i.GroupKey,
+			&i.ReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNotification = `-- name: GetNotification :one
+SELECT id, created_at, user_id, type, actor_id, subject_type, subject_id, group_key, read_at FROM notifications
+WHERE id = $1
+`
+
+func (q *Queries) GetNotification(ctx context.Context, id uuid.UUID) (Notification, error) {
+	row := q.db.QueryRowContext(ctx, getNotification, id)
+	var i Notification
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Type,
+		&i.ActorID,
+		&i.SubjectType,
+		&i.SubjectID,
+		&i.GroupKey,
+		&i.ReadAt,
+	)
+	return i, err
+}
+
+const listNotificationGroups = `-- name: ListNotificationGroups :many
+SELECT
+    group_key,
+    type,
+    subject_type,
+    subject_id,
+    COUNT(*) AS count,
+    array_remove(array_agg(DISTINCT actor_id), NULL)::uuid[] AS actor_ids,
+    MAX(created_at)::timestamp AS latest_at,
+    read_at
+FROM notifications
+WHERE user_id = $1
+AND (NOT $3::boolean OR read_at IS NULL)
+GROUP BY group_key, type, subject_type, subject_id, read_at
+HAVING $4::timestamp IS NULL OR MAX(created_at) < $4::timestamp
+ORDER BY latest_at DESC
+LIMIT $2
+`
+
+type ListNotificationGroupsParams struct {
+	UserID     uuid.UUID
+	Limit      int32
+	UnreadOnly bool
+	Before     sql.NullTime
+}
+
+type ListNotificationGroupsRow struct {
+	GroupKey    string
+	Type        string
+	SubjectType string
+	SubjectID   string
+	Count       int64
+	ActorIds    []uuid.UUID
+	LatestAt    time.Time
+	ReadAt      sql.NullTime
+}
+
+// Unread notifications are grouped by subject; read ones by subject and the
+// batch they were read in.
+func (q *Queries) ListNotificationGroups(ctx context.Context, arg ListNotificationGroupsParams) ([]ListNotificationGroupsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listNotificationGroups,
+		arg.UserID,
+		arg.Limit,
+		arg.UnreadOnly,
+		arg.Before,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListNotificationGroupsRow
+	for rows.Next() {
+		var i ListNotificationGroupsRow
+		if err := rows.Scan(
+			&i.GroupKey,
+			&i.Type,
+			&i.SubjectType,
+			&i.SubjectID,
+			&i.Count,
+			pq.Array(&i.ActorIds),
+			&i.LatestAt,
+			&i.ReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listNotificationPreferences = `-- name: ListNotificationPreferences :many
+SELECT user_id, type, enabled, updated_at FROM notification_preferences
+WHERE user_id = $1
+`
+
+func (q *Queries) ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]NotificationPreference, error) {
+	rows, err := q.db.QueryContext(ctx, listNotificationPreferences, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []NotificationPreference
+	for rows.Next() {
+		var i NotificationPreference
+		if err := rows.Scan(
+			&i.UserID,
+			&i.Type,
+			&i.Enabled,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markAllNotificationsRead = `-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL
+`
+
+func (q *Queries) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markAllNotificationsRead, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markNotificationGroupRead = `-- name: MarkNotificationGroupRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND group_key = $2
+AND read_at IS NULL
+`
+
+type MarkNotificationGroupReadParams struct {
+	UserID   uuid.UUID
+	GroupKey string
+}
+
+func (q *Queries) MarkNotificationGroupRead(ctx context.Context, arg MarkNotificationGroupReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markNotificationGroupRead, arg.UserID, arg.GroupKey)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const setNotificationPreference = `-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled, updated_at = NOW()
+`
+
+type SetNotificationPreferenceParams struct {
+	UserID  uuid.UUID
+	Type    string
+	Enabled bool
+}
+
+func (q *Queries) SetNotificationPreference(ctx context.Context, arg SetNotificationPreferenceParams) error {
+	_, err := q.db.ExecContext(ctx, setNotificationPreference, arg.UserID, arg.Type, arg.Enabled)
+	return err
+}
@@ -0,0 +1,183 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: polls.sql
+
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const castPollVote = `-- name: CastPollVote :execrows
+INSERT INTO poll_votes (chirp_id, user_id, position, created_at)
+SELECT polls.chirp_id, $1, $2, NOW()
+FROM polls
+WHERE polls.chirp_id = $3
+AND polls.closes_at > NOW()
+ON CONFLICT (chirp_id, user_id) DO NOTHING
+`
+
+type CastPollVoteParams struct {
+	UserID   uuid.UUID
+	Position int32
+	ChirpID  uuid.UUID
+}
+
+// Records nothing if the user has already voted or the poll has closed.
+func (q *Queries) CastPollVote(ctx context.Context, arg CastPollVoteParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, castPollVote, arg.UserID, arg.Position, arg.ChirpID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createPoll = `-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW() + make_interval(secs => $2::float8)
+)
+`
+
+type CreatePollParams struct {
+	ChirpID      uuid.UUID
+	DelaySeconds float64
+}
+
+func (q *Queries) CreatePoll(ctx context.Context, arg CreatePollParams) error {
+	_, err := q.db.ExecContext(ctx, createPoll, arg.ChirpID, arg.DelaySeconds)
+	return err
+}
+
+const createPollOption = `-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+VALUES ($1, $2, $3)
+`
+
+type CreatePollOptionParams struct {
+	ChirpID  uuid.UUID
+	Position int32
+	Text     string
+}
+
+func (q *Queries) CreatePollOption(ctx context.Context, arg CreatePollOptionParams) error {
+	_, err := q.db.ExecContext(ctx, createPollOption, arg.ChirpID, arg.Position, arg.Text)
+	return err
+}
+
+const exportPollVotesByUser = `-- name: ExportPollVotesByUser :many
+SELECT poll_votes.chirp_id, poll_votes.created_at, poll_options.text AS option
+FROM poll_votes
+JOIN poll_options ON poll_options.chirp_id = poll_votes.chirp_id
+    AND poll_options.position = poll_votes.position
+WHERE poll_votes.user_id = $1
+ORDER BY poll_votes.created_at ASC
+`
+
+type ExportPollVotesByUserRow struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	Option    string
+}
+
+func (q *Queries) ExportPollVotesByUser(ctx context.Context, userID uuid.UUID) ([]ExportPollVotesByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, exportPollVotesByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ExportPollVotesByUserRow
+	for rows.Next() {
+		var i ExportPollVotesByUserRow
+		if err := rows.Scan(&i.ChirpID, &i.CreatedAt, &i.Option); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPollResults = `-- name: GetPollResults :many
+SELECT
+    polls.chirp_id,
+    polls.closes_at,
+    (polls.closes_at <= NOW())::boolean AS closed,
+    poll_options.position,
+    poll_options.text,
+    (
+        SELECT COUNT(*) FROM poll_votes
+        WHERE poll_votes.chirp_id = poll_options.chirp_id
+        AND poll_votes.position = poll_options.position
+    ) AS votes,
+    EXISTS (
+        SELECT 1 FROM poll_votes
+        WHERE poll_votes.chirp_id = poll_options.chirp_id
+        AND poll_votes.position = poll_options.position
+        AND poll_votes.user_id = $1
+    ) AS viewer_voted
+FROM polls
+JOIN poll_options ON poll_options.chirp_id = polls.chirp_id
+WHERE polls.chirp_id = ANY($2::uuid[])
+ORDER BY polls.chirp_id, poll_options.position
+`
+
+type GetPollResultsParams struct {
+	ViewerID uuid.NullUUID
+	ChirpIds []uuid.UUID
+}
+
+type GetPollResultsRow struct {
+	ChirpID     uuid.UUID
+	ClosesAt    time.Time
+	Closed      bool
+	Position    int32
+	Text        string
+	Votes       int64
+	ViewerVoted bool
+}
+
+// One row per option of each poll on the given chirps, with its vote count
+// and whether the viewer chose it.
+func (q *Queries) GetPollResults(ctx context.Context, arg GetPollResultsParams) ([]GetPollResultsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPollResults, arg.ViewerID, pq.Array(arg.ChirpIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPollResultsRow
+	for rows.Next() {
+		var i GetPollResultsRow
+		if err := rows.Scan(
+			&i.ChirpID,
+			&i.ClosesAt,
+			&i.Closed,
+			&i.Position,
+			&i.Text,
+			&i.Votes,
+			&i.ViewerVoted,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,339 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: reports.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const claimReport = `-- name: ClaimReport :one
+UPDATE reports
+SET status = 'claimed', assignee_id = $2, updated_at = NOW()
+WHERE id = $1
+AND (status = 'open' OR (status = 'claimed' AND assignee_id = $2))
+RETURNING id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, status, assignee_id, resolution, resolved_at, resolved_by
+`
+
+type ClaimReportParams struct {
+	ID         uuid.UUID
+	AssigneeID uuid.NullUUID
+}
+
+func (q *Queries) ClaimReport(ctx context.Context, arg ClaimReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, claimReport, arg.ID, arg.AssigneeID)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.ReportedUserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Status,
+		&i.AssigneeID,
+		&i.Resolution,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
+
+const createReport = `-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, status, assignee_id, resolution, resolved_at, resolved_by
+`
+
+type CreateReportParams struct {
+	ReporterID     uuid.NullUUID
+	ReportedUserID uuid.UUID
+	ChirpID        uuid.NullUUID
+	Reason         string
+}
+
+func (q *Queries) CreateReport(ctx context.Context, arg CreateReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, createReport,
+		arg.ReporterID,
+		arg.ReportedUserID,
+		arg.ChirpID,
+		arg.Reason,
+	)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.ReportedUserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Status,
+		&i.AssigneeID,
+		&i.Resolution,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
+
+const createReportNote = `-- name: CreateReportNote :one
+INSERT INTO report_notes (id, created_at, report_id, author_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING id, created_at, report_id, author_id, body
+`
+
+type CreateReportNoteParams struct {
+	ReportID uuid.UUID
+	AuthorID uuid.NullUUID
+	Body     string
+}
+
+func (q *Queries) CreateReportNote(ctx context.Context, arg CreateReportNoteParams) (ReportNote, error) {
+	row := q.db.QueryRowContext(ctx, createReportNote, arg.ReportID, arg.AuthorID, arg.Body)
+	var i ReportNote
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.ReportID,
+		&i.AuthorID,
+		&i.Body,
+	)
+	return i, err
+}
+
+const getReport = `-- name: GetReport :one
+SELECT id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, status, assignee_id, resolution, resolved_at, resolved_by FROM reports
+WHERE id = $1
+`
+
+func (q *Queries) GetReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, getReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.ReportedUserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Status,
+		&i.AssigneeID,
+		&i.Resolution,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
+
+const listReportNotes = `-- name: ListReportNotes :many
+SELECT id, created_at, report_id, author_id, body FROM report_notes
+WHERE report_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListReportNotes(ctx context.Context, reportID uuid.UUID) ([]ReportNote, error) {
+	rows, err := q.db.QueryContext(ctx, listReportNotes, reportID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ReportNote
+	for rows.Next() {
+		var i ReportNote
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.ReportID,
+			&i.AuthorID,
+			&i.Body,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReports = `-- name: ListReports :many
+SELECT id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, status, assignee_id, resolution, resolved_at, resolved_by FROM reports
+WHERE ($3::text IS NULL OR status = $3)
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2
+`
+
+type ListReportsParams struct {
+	Limit  int32
+	Offset int32
+	Status sql.NullString
+}
+
+func (q *Queries) ListReports(ctx context.Context, arg ListReportsParams) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, listReports, arg.Limit, arg.Offset, arg.Status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ReporterID,
+			&i.ReportedUserID,
+			&i.ChirpID,
+			&i.Reason,
+			&i.Status,
+			&i.AssigneeID,
+			&i.Resolution,
+			&i.ResolvedAt,
+			&i.ResolvedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listReportsByReporter = `-- name: ListReportsByReporter :many
+SELECT id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, status, assignee_id, resolution, resolved_at, resolved_by FROM reports
+WHERE reporter_id = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListReportsByReporter(ctx context.Context, reporterID uuid.NullUUID) ([]Report, error) {
+	rows, err := q.db.QueryContext(ctx, listReportsByReporter, reporterID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Report
+	for rows.Next() {
+		var i Report
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ReporterID,
+			&i.ReportedUserID,
+			&i.ChirpID,
+			&i.Reason,
+			&i.Status,
+			&i.AssigneeID,
+			&i.Resolution,
+			&i.ResolvedAt,
+			&i.ResolvedBy,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockReport = `-- name: LockReport :one
+SELECT id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, status, assignee_id, resolution, resolved_at, resolved_by FROM reports
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) LockReport(ctx context.Context, id uuid.UUID) (Report, error) {
+	row := q.db.QueryRowContext(ctx, lockReport, id)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.ReportedUserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Status,
+		&i.AssigneeID,
+		&i.Resolution,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
+
+const resolveReport = `-- name: ResolveReport :one
+UPDATE reports
+SET status = 'resolved',
+    resolution = $2,
+    assignee_id = COALESCE(assignee_id, $3),
+    resolved_by = $3,
+    resolved_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND status <> 'resolved'
+RETURNING id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason, status, assignee_id, resolution, resolved_at, resolved_by
+`
+
+type ResolveReportParams struct {
+	ID          uuid.UUID
+	Resolution  sql.NullString
+	ModeratorID uuid.NullUUID
+}
+
+func (q *Queries) ResolveReport(ctx context.Context, arg ResolveReportParams) (Report, error) {
+	row := q.db.QueryRowContext(ctx, resolveReport, arg.ID, arg.Resolution, arg.ModeratorID)
+	var i Report
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ReporterID,
+		&i.ReportedUserID,
+		&i.ChirpID,
+		&i.Reason,
+		&i.Status,
+		&i.AssigneeID,
+		&i.Resolution,
+		&i.ResolvedAt,
+		&i.ResolvedBy,
+	)
+	return i, err
+}
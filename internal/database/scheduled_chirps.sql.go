@@ -0,0 +1,209 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: scheduled_chirps.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const countScheduledChirps = `-- name: CountScheduledChirps :one
+SELECT COUNT(*) FROM scheduled_chirps
+WHERE user_id = $1
+`
+
+func (q *Queries) CountScheduledChirps(ctx context.Context, userID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countScheduledChirps, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createScheduledChirp = `-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    NOW() + make_interval(secs => $3::double precision)
+)
+RETURNING id, created_at, updated_at, user_id, body, publish_at, status, error
+`
+
+type CreateScheduledChirpParams struct {
+	UserID       uuid.UUID
+	Body         string
+	DelaySeconds float64
+}
+
+func (q *Queries) CreateScheduledChirp(ctx context.Context, arg CreateScheduledChirpParams) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, createScheduledChirp, arg.UserID, arg.Body, arg.DelaySeconds)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.PublishAt,
+		&i.Status,
+		&i.Error,
+	)
+	return i, err
+}
+
+const deleteScheduledChirp = `-- name: DeleteScheduledChirp :exec
+DELETE FROM scheduled_chirps
+WHERE id = $1
+`
+
+func (q *Queries) DeleteScheduledChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteScheduledChirp, id)
+	return err
+}
+
+const failScheduledChirp = `-- name: FailScheduledChirp :exec
+UPDATE scheduled_chirps
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type FailScheduledChirpParams struct {
+	ID    uuid.UUID
+	Error sql.NullString
+}
+
+func (q *Queries) FailScheduledChirp(ctx context.Context, arg FailScheduledChirpParams) error {
+	_, err := q.db.ExecContext(ctx, failScheduledChirp, arg.ID, arg.Error)
+	return err
+}
+
+const getScheduledChirp = `-- name: GetScheduledChirp :one
+SELECT id, created_at, updated_at, user_id, body, publish_at, status, error FROM scheduled_chirps
+WHERE id = $1
+`
+
+func (q *Queries) GetScheduledChirp(ctx context.Context, id uuid.UUID) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, getScheduledChirp, id)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.PublishAt,
+		&i.Status,
+		&i.Error,
+	)
+	return i, err
+}
+
+const listScheduledChirps = `-- name: ListScheduledChirps :many
+SELECT id, created_at, updated_at, user_id, body, publish_at, status, error FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC
+`
+
+func (q *Queries) ListScheduledChirps(ctx context.Context, userID uuid.UUID) ([]ScheduledChirp, error) {
+	rows, err := q.db.QueryContext(ctx, listScheduledChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ScheduledChirp
+	for rows.Next() {
+		var i ScheduledChirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.Body,
+			&i.PublishAt,
+			&i.Status,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockDueScheduledChirp = `-- name: LockDueScheduledChirp :one
+SELECT id, created_at, updated_at, user_id, body, publish_at, status, error FROM scheduled_chirps
+WHERE id = $1
+AND status = 'pending'
+AND publish_at <= NOW()
+FOR UPDATE
+`
+
+// Returns the scheduled chirp if it is due, locking it until the end of the
+// transaction so that it can't be edited or published twice meanwhile.
+func (q *Queries) LockDueScheduledChirp(ctx context.Context, id uuid.UUID) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, lockDueScheduledChirp, id)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.PublishAt,
+		&i.Status,
+		&i.Error,
+	)
+	return i, err
+}
+
+const updateScheduledChirp = `-- name: UpdateScheduledChirp :one
+UPDATE scheduled_chirps
+SET body = $1,
+    publish_at = CASE
+        WHEN $2::double precision IS NULL THEN publish_at
+        ELSE NOW() + make_interval(secs => $2::double precision)
+    END,
+    status = 'pending',
+    error = NULL,
+    updated_at = NOW()
+WHERE id = $3
+RETURNING id, created_at, updated_at, user_id, body, publish_at, status, error
+`
+
+type UpdateScheduledChirpParams struct {
+	Body         string
+	DelaySeconds sql.NullFloat64
+	ID           uuid.UUID
+}
+
+// Saving a scheduled chirp puts it back in the queue, so a failed one can
+// be fixed and retried. publish_at is kept if delay_seconds is NULL.
+func (q *Queries) UpdateScheduledChirp(ctx context.Context, arg UpdateScheduledChirpParams) (ScheduledChirp, error) {
+	row := q.db.QueryRowContext(ctx, updateScheduledChirp, arg.Body, arg.DelaySeconds, arg.ID)
+	var i ScheduledChirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.Body,
+		&i.PublishAt,
+		&i.Status,
+		&i.Error,
+	)
+	return i, err
+}
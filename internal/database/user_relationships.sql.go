@@ -0,0 +1,176 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: user_relationships.sql
+
+package database
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const createUserRelationship = `-- name: CreateUserRelationship :exec
+INSERT INTO user_relationships (user_id, target_id, kind, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT DO NOTHING
+`
+
+type CreateUserRelationshipParams struct {
+	UserID   uuid.UUID
+	TargetID uuid.UUID
+	Kind     string
+}
+
+func (q *Queries) CreateUserRelationship(ctx context.Context, arg CreateUserRelationshipParams) error {
+	_, err := q.db.ExecContext(ctx, createUserRelationship, arg.UserID, arg.TargetID, arg.Kind)
+	return err
+}
+
+const deleteUserRelationship = `-- name: DeleteUserRelationship :execrows
+DELETE FROM user_relationships
+WHERE user_id = $1
+AND target_id = $2
+AND kind = $3
+`
+
+type DeleteUserRelationshipParams struct {
+	UserID   uuid.UUID
+	TargetID uuid.UUID
+	Kind     string
+}
+
+func (q *Queries) DeleteUserRelationship(ctx context.Context, arg DeleteUserRelationshipParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUserRelationship, arg.UserID, arg.TargetID, arg.Kind)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const exportUserRelationships = `-- name: ExportUserRelationships :many
+SELECT user_id, target_id, kind, created_at FROM user_relationships
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ExportUserRelationships(ctx context.Context, userID uuid.UUID) ([]UserRelationship, error) {
+	rows, err := q.db.QueryContext(ctx, exportUserRelationships, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserRelationship
+	for rows.Next() {
+		var i UserRelationship
+		if err := rows.Scan(
+			&i.UserID,
+			&i.TargetID,
+			&i.Kind,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isBlockedEitherWay = `-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND (
+        (user_id = $1 AND target_id = $2)
+        OR (user_id = $2 AND target_id = $1)
+    )
+)
+`
+
+type IsBlockedEitherWayParams struct {
+	UserID   uuid.UUID
+	TargetID uuid.UUID
+}
+
+func (q *Queries) IsBlockedEitherWay(ctx context.Context, arg IsBlockedEitherWayParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isBlockedEitherWay, arg.UserID, arg.TargetID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const isBlockedWithAny = `-- name: IsBlockedWithAny :one
+SELECT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND (
+        (user_id = $1 AND target_id = ANY($2::uuid[]))
+        OR (target_id = $1 AND user_id = ANY($2::uuid[]))
+    )
+)
+`
+
+type IsBlockedWithAnyParams struct {
+	UserID   uuid.UUID
+	OtherIds []uuid.UUID
+}
+
+func (q *Queries) IsBlockedWithAny(ctx context.Context, arg IsBlockedWithAnyParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isBlockedWithAny, arg.UserID, pq.Array(arg.OtherIds))
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const listUserRelationships = `-- name: ListUserRelationships :many
+SELECT user_id, target_id, kind, created_at FROM user_relationships
+WHERE user_id = $1
+AND kind = $2
+ORDER BY created_at DESC
+`
+
+type ListUserRelationshipsParams struct {
+	UserID uuid.UUID
+	Kind   string
+}
+
+func (q *Queries) ListUserRelationships(ctx context.Context, arg ListUserRelationshipsParams) ([]UserRelationship, error) {
+	rows, err := q.db.QueryContext(ctx, listUserRelationships, arg.UserID, arg.Kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []UserRelationship
+	for rows.Next() {
+		var i UserRelationship
+		if err := rows.Scan(
+			&i.UserID,
+			&i.TargetID,
+			&i.Kind,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,421 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: webhooks.sql
+
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const countWebhooksByOwner = `-- name: CountWebhooksByOwner :one
+SELECT COUNT(*) FROM webhooks
+WHERE owner_id = $1
+`
+
+func (q *Queries) CountWebhooksByOwner(ctx context.Context, ownerID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countWebhooksByOwner, ownerID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, owner_id, global, url, secret, events)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, updated_at, owner_id, global, url, secret, events
+`
+
+type CreateWebhookParams struct {
+	OwnerID uuid.UUID
+	Global  bool
+	Url     string
+	Secret  string
+	Events  []string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook,
+		arg.OwnerID,
+		arg.Global,
+		arg.Url,
+		arg.Secret,
+		pq.Array(arg.Events),
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Global,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+	)
+	return i, err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_id, event_type, payload, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+RETURNING id, created_at, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error
+`
+
+type CreateWebhookDeliveryParams struct {
+	WebhookID uuid.UUID
+	EventID   uuid.UUID
+	EventType string
+	Payload   json.RawMessage
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, createWebhookDelivery,
+		arg.WebhookID,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+	)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+	)
+	return i, err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :exec
+DELETE FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	return err
+}
+
+const enqueueWebhookEvent = `-- name: EnqueueWebhookEvent :many
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_id, event_type, payload, next_attempt_at)
+SELECT
+    gen_random_uuid(),
+    NOW(),
+    webhooks.id,
+    $1::uuid,
+    $2::text,
+    $3::jsonb,
+    NOW()
+FROM webhooks
+WHERE $2::text = ANY(webhooks.events)
+AND (webhooks.global OR webhooks.owner_id = $4::uuid)
+RETURNING id
+`
+
+type EnqueueWebhookEventParams struct {
+	EventID       uuid.UUID
+	EventType     string
+	Payload       json.RawMessage
+	SubjectUserID uuid.UUID
+}
+
+// Creates one delivery for every webhook subscribed to the event: global
+// webhooks, and those owned by the user the event is about.
+func (q *Queries) EnqueueWebhookEvent(ctx context.Context, arg EnqueueWebhookEventParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, enqueueWebhookEvent,
+		arg.EventID,
+		arg.EventType,
+		arg.Payload,
+		arg.SubjectUserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, created_at, updated_at, owner_id, global, url, secret, events FROM webhooks
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id uuid.UUID) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Global,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+	)
+	return i, err
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, created_at, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error FROM webhook_deliveries
+WHERE id = $1
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+	)
+	return i, err
+}
+
+const getWebhookDeliveryTarget = `-- name: GetWebhookDeliveryTarget :one
+SELECT webhook_deliveries.id, webhook_deliveries.created_at, webhook_deliveries.webhook_id, webhook_deliveries.event_id, webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at, webhook_deliveries.last_attempt_at, webhook_deliveries.response_status, webhook_deliveries.last_error, webhooks.url, webhooks.secret
+FROM webhook_deliveries
+JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+WHERE webhook_deliveries.id = $1
+`
+
+type GetWebhookDeliveryTargetRow struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	WebhookID      uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastAttemptAt  sql.NullTime
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
+	Url            string
+	Secret         string
+}
+
+func (q *Queries) GetWebhookDeliveryTarget(ctx context.Context, id uuid.UUID) (GetWebhookDeliveryTargetRow, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDeliveryTarget, id)
+	var i GetWebhookDeliveryTargetRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.WebhookID,
+		&i.EventID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.Attempts,
+		&i.NextAttemptAt,
+		&i.LastAttemptAt,
+		&i.ResponseStatus,
+		&i.LastError,
+		&i.Url,
+		&i.Secret,
+	)
+	return i, err
+}
+
+const listWebhookDeliveries = `-- name: ListWebhookDeliveries :many
+SELECT id, created_at, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_attempt_at, response_status, last_error FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2
+`
+
+type ListWebhookDeliveriesParams struct {
+	WebhookID uuid.UUID
+	Limit     int32
+}
+
+func (q *Queries) ListWebhookDeliveries(ctx context.Context, arg ListWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhookDeliveries, arg.WebhookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.WebhookID,
+			&i.EventID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.LastAttemptAt,
+			&i.ResponseStatus,
+			&i.LastError,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWebhooksByOwner = `-- name: ListWebhooksByOwner :many
+SELECT id, created_at, updated_at, owner_id, global, url, secret, events FROM webhooks
+WHERE owner_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListWebhooksByOwner(ctx context.Context, ownerID uuid.UUID) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, listWebhooksByOwner, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.OwnerID,
+			&i.Global,
+			&i.Url,
+			&i.Secret,
+			pq.Array(&i.Events),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordWebhookAttempt = `-- name: RecordWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    last_attempt_at = NOW(),
+    status = $1,
+    next_attempt_at = NOW() + make_interval(secs => COALESCE($2::double precision, 0)),
+    response_status = $3,
+    last_error = $4
+WHERE id = $5
+`
+
+type RecordWebhookAttemptParams struct {
+	Status         string
+	RetrySeconds   sql.NullFloat64
+	ResponseStatus sql.NullInt32
+	LastError      sql.NullString
+	ID             uuid.UUID
+}
+
+// Records the outcome of an attempt. retry_seconds is when the next attempt
+// is due, or NULL if there won't be one.
+func (q *Queries) RecordWebhookAttempt(ctx context.Context, arg RecordWebhookAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, recordWebhookAttempt,
+		arg.Status,
+		arg.RetrySeconds,
+		arg.ResponseStatus,
+		arg.LastError,
+		arg.ID,
+	)
+	return err
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = $2, events = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, owner_id, global, url, secret, events
+`
+
+type UpdateWebhookParams struct {
+	ID     uuid.UUID
+	Url    string
+	Events []string
+}
+
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, updateWebhook, arg.ID, arg.Url, pq.Array(arg.Events))
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.OwnerID,
+		&i.Global,
+		&i.Url,
+		&i.Secret,
+		pq.Array(&i.Events),
+	)
+	return i, err
+}
@@ -0,0 +1,206 @@
+// Package filter checks chirp text against a list of word rules. Each rule
+// names a word and what to do when it appears: mask it, reject the chirp, or
+// flag the chirp for moderator review.
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+type Action string
+
+const (
+	ActionMask   Action = "mask"
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+// Mask replaces each masked word.
+const Mask = "****"
+
+// actionRank orders actions so the strictest wins when the same word is
+// given more than one rule.
+var actionRank = map[Action]int{
+	ActionFlag:   1,
+	ActionMask:   2,
+	ActionReject: 3,
+}
+
+func (a Action) Valid() bool {
+	_, ok := actionRank[a]
+	return ok
+}
+
+type Rule struct {
+	Word   string
+	Action Action
+}
+
+type Match struct {
+	Word   string
+	Action Action
+}
+
+type Result struct {
+	// Text is the input with masked words replaced.
+	Text    string
+	Matches []Match
+}
+
+func (r Result) Rejected() bool {
+	return r.has(ActionReject)
+}
+
+func (r Result) Flagged() bool {
+	return r.has(ActionFlag)
+}
+
+func (r Result) has(action Action) bool {
+	for _, m := range r.Matches {
+		if m.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter is an immutable, compiled set of rules. It is safe for concurrent
+// use.
+type Filter struct {
+	rules map[string]Action
+}
+
+// New compiles rules. Words are matched case-insensitively after Unicode
+// normalisation, so "Kerfuffle" and "ＫＥＲＦＵＦＦＬＥ" match a rule for
+// "kerfuffle".
+func New(rules []Rule) (*Filter, error) {
+	f := &Filter{rules: make(map[string]Action, len(rules))}
+	for _, rule := range rules {
+		if !rule.Action.Valid() {
+			return nil, fmt.Errorf("rule %q: invalid action %q", rule.Word, rule.Action)
+		}
+		word, err := NormalizeWord(rule.Word)
+		if err != nil {
+			return nil, err
+		}
+		if actionRank[rule.Action] > actionRank[f.rules[word]] {
+			f.rules[word] = rule.Action
+		}
+	}
+	return f, nil
+}
+
+// Len reports the number of distinct words in f.
+func (f *Filter) Len() int {
+	return len(f.rules)
+}
+
+// NormalizeWord returns the form a rule word is stored and matched in. It
+// fails unless word is exactly one token.
+func NormalizeWord(word string) (string, error) {
+	tokens := tokenize(word)
+	if len(tokens) != 1 || tokens[0].start != 0 || tokens[0].end != len(word) {
+		return "", fmt.Errorf("rule %q must be a single word", word)
+	}
+	return fold(word), nil
+}
+
+var folder = cases.Fold()
+
+func fold(s string) string {
+	return folder.String(norm.NFKC.String(s))
+}
+
+// Apply checks text against f. A nil Filter matches nothing.
+func (f *Filter) Apply(text string) Result {
+	result := Result{Text: text}
+	if f == nil || len(f.rules) == 0 {
+		return result
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, tok := range tokenize(text) {
+		word := text[tok.start:tok.end]
+		action, ok := f.rules[fold(word)]
+		if !ok {
+			continue
+		}
+		result.Matches = append(result.Matches, Match{Word: word, Action: action})
+		if action == ActionMask {
+			b.WriteString(text[last:tok.start])
+			b.WriteString(Mask)
+			last = tok.end
+		}
+	}
+	if last > 0 {
+		b.WriteString(text[last:])
+		result.Text = b.String()
+	}
+	return result
+}
+
+type token struct {
+	start, end int
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// tokenize splits s into runs of letters, digits and combining marks, so
+// punctuation and whitespace of any script separate words.
+func tokenize(s string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range s {
+		switch {
+		case isWordRune(r) && start < 0:
+			start = i
+		case !isWordRune(r) && start >= 0:
+			tokens = append(tokens, token{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{start, len(s)})
+	}
+	return tokens
+}
+
+// ParseRules reads rules in the file format: one "word action" pair per
+// line, with blank lines and lines starting with # ignored. The action
+// defaults to mask.
+func ParseRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		rule := Rule{Word: fields[0], Action: ActionMask}
+		switch len(fields) {
+		case 1:
+		case 2:
+			rule.Action = Action(fields[1])
+		default:
+			return nil, fmt.Errorf("line %d: expected \"word [action]\"", line)
+		}
+		if !rule.Action.Valid() {
+			return nil, fmt.Errorf("line %d: invalid action %q", line, rule.Action)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
@@ -0,0 +1,171 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	f, err := New([]Rule{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "sharbert", Action: ActionMask},
+		{Word: "fornax", Action: ActionReject},
+		{Word: "crypto", Action: ActionFlag},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		text         string
+		wantText     string
+		wantRejected bool
+		wantFlagged  bool
+	}{
+		{
+			name:     "No matches",
+			text:     "I had something interesting for breakfast",
+			wantText: "I had something interesting for breakfast",
+		},
+		{
+			name:     "Mask keeps punctuation",
+			text:     "What a kerfuffle! Sharbert, again?",
+			wantText: "What a ****! ****, again?",
+		},
+		{
+			name:     "Case folding",
+			text:     "KERFUFFLE Kerfuffle",
+			wantText: "**** ****",
+		},
+		{
+			name:     "Full-width letters",
+			text:     "ｋｅｒｆｕｆｆｌｅ",
+			wantText: "****",
+		},
+		{
+			name:     "Substrings don't match",
+			text:     "kerfuffles are fine",
+			wantText: "kerfuffles are fine",
+		},
+		{
+			name:         "Reject",
+			text:         "to fornax.",
+			wantText:     "to fornax.",
+			wantRejected: true,
+		},
+		{
+			name:        "Flag",
+			text:        "buy (crypto) now",
+			wantText:    "buy (crypto) now",
+			wantFlagged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.Apply(tt.text)
+			if got.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", got.Text, tt.wantText)
+			}
+			if got.Rejected() != tt.wantRejected {
+				t.Errorf("Rejected() = %v, want %v", got.Rejected(), tt.wantRejected)
+			}
+			if got.Flagged() != tt.wantFlagged {
+				t.Errorf("Flagged() = %v, want %v", got.Flagged(), tt.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	if got := f.Apply("kerfuffle"); got.Text != "kerfuffle" || len(got.Matches) != 0 {
+		t.Errorf("nil Filter changed text: %+v", got)
+	}
+}
+
+func TestNewStrictestActionWins(t *testing.T) {
+	f, err := New([]Rule{
+		{Word: "fornax", Action: ActionFlag},
+		{Word: "Fornax", Action: ActionReject},
+		{Word: "FORNAX", Action: ActionMask},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if !f.Apply("fornax").Rejected() {
+		t.Error("expected the reject rule to win")
+	}
+}
+
+func TestNewInvalidRules(t *testing.T) {
+	tests := []Rule{
+		{Word: "two words", Action: ActionMask},
+		{Word: "bang!", Action: ActionMask},
+		{Word: "", Action: ActionMask},
+		{Word: "kerfuffle", Action: "delete"},
+	}
+	for _, rule := range tests {
+		if _, err := New([]Rule{rule}); err == nil {
+			t.Errorf("New(%+v) succeeded, want error", rule)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	input := `
+# default words
+kerfuffle
+fornax reject
+
+crypto flag
+`
+	rules, err := ParseRules(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseRules() error = %v", err)
+	}
+	want := []Rule{
+		{Word: "kerfuffle", Action: ActionMask},
+		{Word: "fornax", Action: ActionReject},
+		{Word: "crypto", Action: ActionFlag},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("got %d rules, want %d", len(rules), len(want))
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+
+	if _, err := ParseRules(strings.NewReader("kerfuffle delete")); err == nil {
+		t.Error("expected error for invalid action")
+	}
+}
+
+func TestReloaderKeepsFilterOnError(t *testing.T) {
+	var rules []Rule
+	var sourceErr error
+	r := NewReloader(func(ctx context.Context) ([]Rule, error) {
+		return rules, sourceErr
+	})
+
+	rules = []Rule{{Word: "kerfuffle", Action: ActionMask}}
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := r.Current().Apply("kerfuffle").Text; got != Mask {
+		t.Fatalf("Text = %q, want %q", got, Mask)
+	}
+
+	sourceErr = errors.New("database down")
+	if err := r.Reload(context.Background()); err == nil {
+		t.Fatal("Reload() succeeded, want error")
+	}
+	if got := r.Current().Apply("kerfuffle").Text; got != Mask {
+		t.Errorf("previous filter was dropped: Text = %q", got)
+	}
+}
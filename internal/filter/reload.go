@@ -0,0 +1,62 @@
+package filter
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Source returns the full current rule set.
+type Source func(ctx context.Context) ([]Rule, error)
+
+// Reloader holds the active Filter and swaps in a new one whenever its
+// source is reloaded, without interrupting in-flight checks.
+type Reloader struct {
+	source  Source
+	current atomic.Pointer[Filter]
+}
+
+func NewReloader(source Source) *Reloader {
+	return &Reloader{source: source}
+}
+
+// Current returns the active filter, or nil before the first successful load.
+func (r *Reloader) Current() *Filter {
+	return r.current.Load()
+}
+
+// Reload rebuilds the filter from the source. On error the previous filter
+// stays active.
+func (r *Reloader) Reload(ctx context.Context) error {
+	rules, err := r.source(ctx)
+	if err != nil {
+		return err
+	}
+	f, err := New(rules)
+	if err != nil {
+		return err
+	}
+	r.current.Store(f)
+	return nil
+}
+
+// Watch reloads every interval and whenever trigger fires, until ctx is
+// done. Failures are logged and the previous filter is kept.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration, trigger <-chan os.Signal) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-trigger:
+			slog.Info("Reloading content filter")
+		}
+		if err := r.Reload(ctx); err != nil {
+			slog.Error("Failed to reload content filter", "error", err)
+		}
+	}
+}
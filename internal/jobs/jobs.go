@@ -0,0 +1,304 @@
+// Package jobs runs background work from a durable queue.
+//
+// Jobs are stored by a Store, which for chirpy is a Postgres table claimed
+// with FOR UPDATE SKIP LOCKED, so any number of processes can run a Pool
+// against the same queue and each job is run by one of them at a time.
+// A claimed job is leased: if its process dies, the lease runs out and the
+// job is claimed again. Handlers should therefore be safe to run more than
+// once.
+//
+// A job whose handler fails is retried with backoff until it has been tried
+// MaxAttempts times, then left in the dead state for an operator to look at
+// and retry.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultMaxAttempts = 5
+	DefaultTimeout     = time.Minute
+)
+
+// Job is a claimed job. Attempt counts from 1 and includes the attempt in
+// progress.
+type Job struct {
+	ID          uuid.UUID
+	Kind        string
+	Payload     json.RawMessage
+	Attempt     int
+	MaxAttempts int
+}
+
+// Final reports whether this is the job's last attempt, after which a
+// failure leaves it dead.
+func (j Job) Final() bool {
+	return j.Attempt >= j.MaxAttempts
+}
+
+// Store persists jobs.
+type Store interface {
+	// Enqueue adds a job. A non-empty uniqueKey makes it a no-op if a job
+	// with the same key was ever enqueued.
+	Enqueue(ctx context.Context, kind string, payload json.RawMessage, maxAttempts int, uniqueKey string) error
+	// Claim leases up to limit due jobs of the given kinds, incrementing
+	// their attempts. Jobs whose lease ran out are due again.
+	Claim(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]Job, error)
+	// Complete, Retry and Bury record the result of an attempt. They return
+	// ErrLeaseLost, changing nothing, if job is no longer running or has
+	// been claimed again since this attempt.
+	Complete(ctx context.Context, job Job) error
+	// Retry makes the job due again after delay.
+	Retry(ctx context.Context, job Job, delay time.Duration, reason string) error
+	// Bury moves the job to the dead state.
+	Bury(ctx context.Context, job Job, reason string) error
+}
+
+// ErrLeaseLost is returned by a Store when an attempt's result arrives after
+// its lease ran out and the job moved on.
+var ErrLeaseLost = errors.New("jobs: lease lost")
+
+// Kind describes a type of job whose arguments are a T, encoded as JSON.
+type Kind[T any] struct {
+	Name        string
+	MaxAttempts int
+	// Timeout bounds one attempt.
+	Timeout time.Duration
+	// Backoff gives the delay before retrying after the given attempt.
+	// It defaults to Backoff.
+	Backoff func(attempt int) time.Duration
+}
+
+func (k Kind[T]) maxAttempts() int {
+	if k.MaxAttempts > 0 {
+		return k.MaxAttempts
+	}
+	return DefaultMaxAttempts
+}
+
+// Encode returns the payload for a job with the given arguments, and its
+// maximum attempts.
+func (k Kind[T]) Encode(args T) (json.RawMessage, int, error) {
+	payload, err := json.Marshal(args)
+	return payload, k.maxAttempts(), err
+}
+
+// Backoff waits 10s after the first failed attempt, multiplying by 4 each
+// time up to an hour.
+func Backoff(attempt int) time.Duration {
+	delay := 10 * time.Second
+	for i := 1; i < attempt && delay < time.Hour; i++ {
+		delay *= 4
+	}
+	return min(delay, time.Hour)
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error as not worth retrying, so the job dies at once.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// Outcome is what happened to a job after an attempt.
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeRetrying  Outcome = "retrying"
+	OutcomeDead      Outcome = "dead"
+)
+
+type handler struct {
+	run     func(ctx context.Context, job Job) error
+	timeout time.Duration
+	backoff func(attempt int) time.Duration
+}
+
+type periodic struct {
+	kind     string
+	interval time.Duration
+	last     time.Time
+}
+
+// Pool claims jobs and runs them on a fixed number of workers.
+type Pool struct {
+	store        Store
+	workers      int
+	pollInterval time.Duration
+	handlers     map[string]handler
+	periodic     []*periodic
+
+	// OnDone, if set, is called after every attempt.
+	OnDone func(job Job, outcome Outcome, elapsed time.Duration)
+}
+
+func NewPool(store Store, workers int) *Pool {
+	return &Pool{
+		store:        store,
+		workers:      workers,
+		pollInterval: time.Second,
+		handlers:     map[string]handler{},
+	}
+}
+
+// Handle registers fn to run jobs of kind k. It must be called before Run.
+func Handle[T any](p *Pool, k Kind[T], fn func(ctx context.Context, job Job, args T) error) {
+	h := handler{
+		timeout: k.Timeout,
+		backoff: k.Backoff,
+		run: func(ctx context.Context, job Job) error {
+			var args T
+			if err := json.Unmarshal(job.Payload, &args); err != nil {
+				return Permanent(fmt.Errorf("decoding arguments: %w", err))
+			}
+			return fn(ctx, job, args)
+		},
+	}
+	if h.timeout <= 0 {
+		h.timeout = DefaultTimeout
+	}
+	if h.backoff == nil {
+		h.backoff = Backoff
+	}
+	p.handlers[k.Name] = h
+}
+
+// Every enqueues a job of kind k, with no arguments, once per interval
+// across all processes running the pool. k must have a handler.
+func Every(p *Pool, k Kind[struct{}], interval time.Duration) {
+	p.periodic = append(p.periodic, &periodic{kind: k.Name, interval: interval})
+}
+
+// Run claims and runs jobs until ctx is cancelled, then waits for jobs in
+// progress to finish.
+func (p *Pool) Run(ctx context.Context) {
+	kinds := make([]string, 0, len(p.handlers))
+	var lease time.Duration
+	for kind, h := range p.handlers {
+		kinds = append(kinds, kind)
+		lease = max(lease, h.timeout)
+	}
+	// Give an attempt that hits its timeout time to record the result
+	// before anyone else can claim the job.
+	lease += 30 * time.Second
+
+	slots := make(chan struct{}, p.workers)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+	for {
+		p.schedule(ctx)
+
+		free := p.workers - len(slots)
+		if free > 0 && len(kinds) > 0 {
+			claimed, err := p.store.Claim(ctx, kinds, free, lease)
+			if err != nil && ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to claim jobs", "error", err)
+			}
+			for _, job := range claimed {
+				slots <- struct{}{}
+				wg.Go(func() {
+					defer func() { <-slots }()
+					// Jobs in progress are allowed to finish on shutdown
+					// rather than being left to be retried.
+					p.run(context.WithoutCancel(ctx), job)
+				})
+			}
+			// A full batch suggests more are waiting.
+			if len(claimed) == free {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Pool) schedule(ctx context.Context) {
+	now := time.Now()
+	for _, job := range p.periodic {
+		slot := now.Truncate(job.interval)
+		if !slot.After(job.last) {
+			continue
+		}
+		key := job.kind + "@" + slot.UTC().Format(time.RFC3339)
+		if err := p.store.Enqueue(ctx, job.kind, json.RawMessage("{}"), DefaultMaxAttempts, key); err != nil {
+			if ctx.Err() == nil {
+				slog.ErrorContext(ctx, "failed to schedule job", "kind", job.kind, "error", err)
+			}
+			continue
+		}
+		job.last = slot
+	}
+}
+
+func (p *Pool) run(ctx context.Context, job Job) {
+	start := time.Now()
+	h := p.handlers[job.Kind]
+
+	var err error
+	if job.Attempt > job.MaxAttempts {
+		// The last attempt's process died without recording a result.
+		err = Permanent(errors.New("abandoned: lease expired on final attempt"))
+	} else {
+		err = p.attempt(ctx, h, job)
+	}
+
+	var outcome Outcome
+	var storeErr error
+	var permanent permanentError
+	switch {
+	case err == nil:
+		outcome = OutcomeSucceeded
+		storeErr = p.store.Complete(ctx, job)
+	case errors.As(err, &permanent) || job.Final():
+		outcome = OutcomeDead
+		slog.ErrorContext(ctx, "job failed", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempt, "error", err)
+		storeErr = p.store.Bury(ctx, job, err.Error())
+	default:
+		outcome = OutcomeRetrying
+		slog.WarnContext(ctx, "job failed, will retry", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempt, "error", err)
+		storeErr = p.store.Retry(ctx, job, h.backoff(job.Attempt), err.Error())
+	}
+	switch {
+	case errors.Is(storeErr, ErrLeaseLost):
+		slog.WarnContext(ctx, "discarded job result after lease was lost", "job_id", job.ID, "kind", job.Kind, "attempt", job.Attempt, "outcome", outcome)
+	case storeErr != nil:
+		slog.ErrorContext(ctx, "failed to record job result", "job_id", job.ID, "kind", job.Kind, "error", storeErr)
+	}
+	if p.OnDone != nil {
+		p.OnDone(job, outcome, time.Since(start))
+	}
+}
+
+func (p *Pool) attempt(ctx context.Context, h handler, job Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "job panicked", "job_id", job.ID, "kind", job.Kind, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h.run(ctx, job)
+}
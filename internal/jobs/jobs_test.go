@@ -0,0 +1,304 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type memJob struct {
+	Job
+	status    string
+	uniqueKey string
+	delay     time.Duration
+	reason    string
+}
+
+// memStore is a Store that keeps jobs in memory, with every job due as
+// soon as it is pending.
+type memStore struct {
+	mu   sync.Mutex
+	jobs []*memJob
+}
+
+func (s *memStore) Enqueue(ctx context.Context, kind string, payload json.RawMessage, maxAttempts int, uniqueKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if uniqueKey != "" && j.uniqueKey == uniqueKey {
+			return nil
+		}
+	}
+	s.jobs = append(s.jobs, &memJob{
+		Job:       Job{ID: uuid.New(), Kind: kind, Payload: payload, MaxAttempts: maxAttempts},
+		status:    "pending",
+		uniqueKey: uniqueKey,
+	})
+	return nil
+}
+
+func (s *memStore) Claim(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var claimed []Job
+	for _, j := range s.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if j.status == "pending" && slices.Contains(kinds, j.Kind) {
+			j.status = "running"
+			j.Attempt++
+			claimed = append(claimed, j.Job)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memStore) set(job Job, status string, delay time.Duration, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.ID == job.ID {
+			if j.status != "running" || j.Attempt != job.Attempt {
+				return ErrLeaseLost
+			}
+			j.status, j.delay, j.reason = status, delay, reason
+		}
+	}
+	return nil
+}
+
+func (s *memStore) Complete(ctx context.Context, job Job) error {
+	return s.set(job, "succeeded", 0, "")
+}
+
+func (s *memStore) Retry(ctx context.Context, job Job, delay time.Duration, reason string) error {
+	return s.set(job, "pending", delay, reason)
+}
+
+func (s *memStore) Bury(ctx context.Context, job Job, reason string) error {
+	return s.set(job, "dead", 0, reason)
+}
+
+func (s *memStore) snapshot() []memJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []memJob
+	for _, j := range s.jobs {
+		out = append(out, *j)
+	}
+	return out
+}
+
+// runUntil runs the pool until done reports true for the store's jobs.
+func runUntil(t *testing.T, p *Pool, s *memStore, done func([]memJob) bool) []memJob {
+	t.Helper()
+	p.pollInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		p.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		if jobs := s.snapshot(); done(jobs) {
+			return jobs
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timed out; jobs: %+v", s.snapshot())
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func allSettled(jobs []memJob) bool {
+	for _, j := range jobs {
+		if j.status != "succeeded" && j.status != "dead" {
+			return false
+		}
+	}
+	return true
+}
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func TestPoolRunsTypedHandler(t *testing.T) {
+	s := &memStore{}
+	kind := Kind[greeting]{Name: "greet"}
+	payload, maxAttempts, err := kind.Encode(greeting{Name: "chirpy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Enqueue(context.Background(), kind.Name, payload, maxAttempts, "")
+
+	var got string
+	p := NewPool(s, 2)
+	Handle(p, kind, func(ctx context.Context, job Job, args greeting) error {
+		got = args.Name
+		return nil
+	})
+	jobs := runUntil(t, p, s, allSettled)
+
+	if jobs[0].status != "succeeded" || got != "chirpy" {
+		t.Errorf("status %q, got name %q", jobs[0].status, got)
+	}
+}
+
+func TestPoolRetriesThenBuries(t *testing.T) {
+	s := &memStore{}
+	kind := Kind[struct{}]{Name: "flaky", MaxAttempts: 3}
+	payload, maxAttempts, _ := kind.Encode(struct{}{})
+	s.Enqueue(context.Background(), kind.Name, payload, maxAttempts, "")
+
+	var outcomes []Outcome
+	var mu sync.Mutex
+	p := NewPool(s, 1)
+	p.OnDone = func(job Job, outcome Outcome, elapsed time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		outcomes = append(outcomes, outcome)
+	}
+	Handle(p, kind, func(ctx context.Context, job Job, args struct{}) error {
+		return errors.New("boom")
+	})
+	jobs := runUntil(t, p, s, allSettled)
+
+	if jobs[0].status != "dead" || jobs[0].Attempt != 3 || jobs[0].reason != "boom" {
+		t.Errorf("job = %+v, want dead after 3 attempts", jobs[0])
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	want := []Outcome{OutcomeRetrying, OutcomeRetrying, OutcomeDead}
+	if !slices.Equal(outcomes, want) {
+		t.Errorf("outcomes = %v, want %v", outcomes, want)
+	}
+}
+
+func TestPoolPermanentError(t *testing.T) {
+	s := &memStore{}
+	kind := Kind[struct{}]{Name: "doomed"}
+	payload, maxAttempts, _ := kind.Encode(struct{}{})
+	s.Enqueue(context.Background(), kind.Name, payload, maxAttempts, "")
+
+	p := NewPool(s, 1)
+	Handle(p, kind, func(ctx context.Context, job Job, args struct{}) error {
+		return Permanent(errors.New("no such thing"))
+	})
+	jobs := runUntil(t, p, s, allSettled)
+
+	if jobs[0].status != "dead" || jobs[0].Attempt != 1 {
+		t.Errorf("job = %+v, want dead after 1 attempt", jobs[0])
+	}
+}
+
+func TestPoolRecoversPanic(t *testing.T) {
+	s := &memStore{}
+	kind := Kind[struct{}]{Name: "panicky", MaxAttempts: 1}
+	payload, maxAttempts, _ := kind.Encode(struct{}{})
+	s.Enqueue(context.Background(), kind.Name, payload, maxAttempts, "")
+
+	p := NewPool(s, 1)
+	Handle(p, kind, func(ctx context.Context, job Job, args struct{}) error {
+		panic("oops")
+	})
+	jobs := runUntil(t, p, s, allSettled)
+
+	if jobs[0].status != "dead" || jobs[0].reason != "panic: oops" {
+		t.Errorf("job = %+v, want dead with the panic as reason", jobs[0])
+	}
+}
+
+func TestPoolBadPayloadIsPermanent(t *testing.T) {
+	s := &memStore{}
+	kind := Kind[greeting]{Name: "greet"}
+	s.Enqueue(context.Background(), kind.Name, json.RawMessage(`"not an object"`), 5, "")
+
+	p := NewPool(s, 1)
+	Handle(p, kind, func(ctx context.Context, job Job, args greeting) error { return nil })
+	jobs := runUntil(t, p, s, allSettled)
+
+	if jobs[0].status != "dead" || jobs[0].Attempt != 1 {
+		t.Errorf("job = %+v, want dead after 1 attempt", jobs[0])
+	}
+}
+
+func TestPoolDiscardsResultAfterLeaseLost(t *testing.T) {
+	s := &memStore{}
+	kind := Kind[struct{}]{Name: "slow"}
+	payload, maxAttempts, _ := kind.Encode(struct{}{})
+	s.Enqueue(context.Background(), kind.Name, payload, maxAttempts, "")
+
+	p := NewPool(s, 1)
+	var outcomes []Outcome
+	p.OnDone = func(job Job, outcome Outcome, elapsed time.Duration) {
+		outcomes = append(outcomes, outcome)
+	}
+	Handle(p, kind, func(ctx context.Context, job Job, args struct{}) error {
+		// Stand in for the lease running out and another worker claiming
+		// the job while this attempt is still going.
+		s.mu.Lock()
+		s.jobs[0].Attempt++
+		s.mu.Unlock()
+		return nil
+	})
+	claimed, _ := s.Claim(context.Background(), []string{kind.Name}, 1, time.Minute)
+	p.run(context.Background(), claimed[0])
+
+	jobs := s.snapshot()
+	if jobs[0].status != "running" || jobs[0].Attempt != 2 {
+		t.Errorf("job = %+v, want still running on attempt 2", jobs[0])
+	}
+	if !slices.Equal(outcomes, []Outcome{OutcomeSucceeded}) {
+		t.Errorf("outcomes = %v, want [succeeded]", outcomes)
+	}
+}
+
+func TestEverySchedulesOncePerInterval(t *testing.T) {
+	s := &memStore{}
+	kind := Kind[struct{}]{Name: "tick"}
+
+	// Two pools stand in for two processes sharing the queue.
+	for range 2 {
+		p := NewPool(s, 1)
+		Every(p, kind, time.Hour)
+		p.schedule(context.Background())
+		p.schedule(context.Background())
+	}
+
+	jobs := s.snapshot()
+	if len(jobs) != 1 || jobs[0].Kind != "tick" {
+		t.Errorf("got %d jobs, want 1: %+v", len(jobs), jobs)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 40 * time.Second},
+		{3, 160 * time.Second},
+		{10, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
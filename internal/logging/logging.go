@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// New builds a logger writing format ("json" or "text") at level ("debug",
+// "info", "warn" or "error"). Records logged with a request context carry
+// that request's ID and, once known, the authenticated user ID.
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if info := fromContext(ctx); info != nil {
+		r.AddAttrs(slog.String("request_id", info.id))
+		if userID := info.getUserID(); userID != "" {
+			r.AddAttrs(slog.String("user_id", userID))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+type ctxKey struct{}
+
+// requestInfo is shared by pointer so that handlers deeper in the chain can
+// fill in the user ID for the access log written by the outer middleware.
+type requestInfo struct {
+	id     string
+	mu     sync.Mutex
+	userID string
+}
+
+func (info *requestInfo) getUserID() string {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.userID
+}
+
+func fromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(ctxKey{}).(*requestInfo)
+	return info
+}
+
+// NewContext returns a copy of ctx carrying requestID.
+func NewContext(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &requestInfo{id: requestID})
+}
+
+// RequestID returns the request ID stored in ctx, or "".
+func RequestID(ctx context.Context) string {
+	if info := fromContext(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user for the request in ctx.
+func SetUserID(ctx context.Context, userID uuid.UUID) {
+	if info := fromContext(ctx); info != nil {
+		info.mu.Lock()
+		info.userID = userID.String()
+		info.mu.Unlock()
+	}
+}
+
+// UserID returns the user recorded with SetUserID, or "".
+func UserID(ctx context.Context) string {
+	if info := fromContext(ctx); info != nil {
+		return info.getUserID()
+	}
+	return ""
+}
+
+const maxRequestIDLength = 128
+
+// RequestIDFromHeader returns the X-Request-ID supplied by the client if it is
+// short and made of safe characters, and a fresh UUID otherwise.
+func RequestIDFromHeader(value string) string {
+	if value == "" || len(value) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for _, c := range value {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return uuid.NewString()
+		}
+	}
+	return value
+}
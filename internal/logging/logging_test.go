@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewIncludesRequestContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	userID := uuid.New()
+	ctx := NewContext(context.Background(), "req-123")
+	SetUserID(ctx, userID)
+	logger.InfoContext(ctx, "hello")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if line["request_id"] != "req-123" {
+		t.Errorf("request_id = %v, want req-123", line["request_id"])
+	}
+	if line["user_id"] != userID.String() {
+		t.Errorf("user_id = %v, want %v", line["user_id"], userID)
+	}
+}
+
+func TestNewRejectsBadOptions(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := New(&bytes.Buffer{}, "text", "loud"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestRequestIDFromHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		wantSame bool
+	}{
+		{name: "Valid ID", value: "abc-123_DEF.4:5", wantSame: true},
+		{name: "Empty", value: "", wantSame: false},
+		{name: "Too long", value: strings.Repeat("a", maxRequestIDLength+1), wantSame: false},
+		{name: "Unsafe characters", value: "abc\n123", wantSame: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RequestIDFromHeader(tt.value)
+			if (got == tt.value) != tt.wantSame {
+				t.Errorf("RequestIDFromHeader(%q) = %q", tt.value, got)
+			}
+			if got == "" {
+				t.Error("RequestIDFromHeader returned empty ID")
+			}
+		})
+	}
+}
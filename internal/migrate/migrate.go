@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"github.com/ppllama/chirpy/sql/schema"
+)
+
+var (
+	ErrSchemaBehind = errors.New("database schema is behind the code, run `chirpy migrate up`")
+	ErrSchemaAhead  = errors.New("database schema is ahead of the code, deploy a newer build or run `chirpy migrate down`")
+)
+
+// Migrator applies the migrations embedded from sql/schema. Up and Down take
+// a Postgres advisory lock first, so instances starting in parallel apply
+// each migration exactly once.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, schema.FS,
+		goose.WithSessionLocker(locker),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Versions returns the version the database is at and the newest version
+// embedded in the binary.
+func (m *Migrator) Versions(ctx context.Context) (current, expected int64, err error) {
+	current, err = m.provider.GetDBVersion(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	sources := m.provider.ListSources()
+	if len(sources) > 0 {
+		expected = sources[len(sources)-1].Version
+	}
+	return current, expected, nil
+}
+
+// Check reports whether the database is at exactly the version the code
+// expects, returning ErrSchemaBehind or ErrSchemaAhead otherwise.
+func (m *Migrator) Check(ctx context.Context) error {
+	current, expected, err := m.Versions(ctx)
+	if err != nil {
+		return err
+	}
+	switch {
+	case current < expected:
+		return fmt.Errorf("%w (database at %d, code expects %d)", ErrSchemaBehind, current, expected)
+	case current > expected:
+		return fmt.Errorf("%w (database at %d, code expects %d)", ErrSchemaAhead, current, expected)
+	}
+	return nil
+}
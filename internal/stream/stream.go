@@ -0,0 +1,131 @@
+// Package stream fans live events out to subscribers, such as Server-Sent
+// Events and WebSocket connections.
+package stream
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Event is one message for subscribers. Topic says what it is about, e.g.
+// the timeline or one conversation. ID is the event's position in a
+// resumable feed, or zero for events that can't be resumed.
+type Event struct {
+	ID            int64
+	Topic         string
+	Type          string
+	AuthorID      uuid.UUID
+	AuthorLimited bool
+	Data          []byte
+}
+
+// Broker delivers published events to every current subscriber. Publishing
+// never blocks: a subscriber that falls more than its buffer behind is
+// dropped, and is expected to reconnect and resume from its last event.
+type Broker struct {
+	buffer int
+
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewBroker(buffer int) *Broker {
+	return &Broker{
+		buffer: buffer,
+		subs:   map[*Subscription]struct{}{},
+	}
+}
+
+type Subscription struct {
+	broker *Broker
+	match  func(Event) bool
+	events chan Event
+}
+
+// Events is closed when the subscription is closed or dropped.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unsubscribes s. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// Subscribe registers a new subscriber for the events match accepts, or all
+// events if match is nil. match is called while publishing, so it must be
+// quick and must not call back into the Broker. After Close, the
+// subscription Subscribe returns is already closed.
+func (b *Broker) Subscribe(match func(Event) bool) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &Subscription{broker: b, match: match, events: make(chan Event, b.buffer)}
+	if b.closed {
+		close(s.events)
+		return s
+	}
+	b.subs[s] = struct{}{}
+	return s
+}
+
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if s.match != nil && !s.match(e) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			b.remove(s)
+		}
+	}
+}
+
+// Len reports the number of subscribers.
+func (b *Broker) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close ends every subscription and refuses new ones, so open streams finish
+// when the server shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for s := range b.subs {
+		b.remove(s)
+	}
+}
+
+// remove must be called with b.mu held.
+func (b *Broker) remove(s *Subscription) {
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+	delete(b.subs, s)
+	close(s.events)
+}
+
+// Write writes e in the SSE wire format. e.Data must not contain newlines,
+// which holds for encoding/json output.
+func Write(w io.Writer, e Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
+
+// WriteHeartbeat writes an SSE comment, which keeps idle connections open
+// through proxies without delivering an event.
+func WriteHeartbeat(w io.Writer) error {
+	_, err := io.WriteString(w, ": heartbeat\n\n")
+	return err
+}
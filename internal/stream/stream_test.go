@@ -0,0 +1,81 @@
+package stream
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBrokerFanOut(t *testing.T) {
+	b := NewBroker(4)
+	s1 := b.Subscribe(nil)
+	s2 := b.Subscribe(nil)
+
+	b.Publish(Event{ID: 1, Type: "chirp.created"})
+
+	for i, s := range []*Subscription{s1, s2} {
+		e, ok := <-s.Events()
+		if !ok || e.ID != 1 {
+			t.Errorf("subscriber %d got %+v, %v", i, e, ok)
+		}
+	}
+}
+
+func TestBrokerMatch(t *testing.T) {
+	b := NewBroker(4)
+	s := b.Subscribe(func(e Event) bool { return e.Topic == "timeline" })
+
+	b.Publish(Event{ID: 1, Topic: "conversation:x"})
+	b.Publish(Event{ID: 2, Topic: "timeline"})
+	b.Close()
+
+	var got []int64
+	for e := range s.Events() {
+		got = append(got, e.ID)
+	}
+	if len(got) != 1 || got[0] != 2 {
+		t.Errorf("got events %v, want [2]", got)
+	}
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(1)
+	slow := b.Subscribe(nil)
+
+	b.Publish(Event{ID: 1})
+	b.Publish(Event{ID: 2})
+
+	if b.Len() != 0 {
+		t.Fatalf("Len() = %d, want 0", b.Len())
+	}
+	if e := <-slow.Events(); e.ID != 1 {
+		t.Errorf("first event ID = %d, want 1", e.ID)
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Error("expected events channel to be closed")
+	}
+	slow.Close()
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(1)
+	s := b.Subscribe(nil)
+	b.Close()
+
+	if _, ok := <-s.Events(); ok {
+		t.Error("expected open subscription to be closed")
+	}
+	if _, ok := <-b.Subscribe(nil).Events(); ok {
+		t.Error("expected new subscription after Close to be closed")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, Event{ID: 42, Type: "chirp.deleted", Data: []byte(`{"id":"x"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	want := "id: 42\nevent: chirp.deleted\ndata: {\"id\":\"x\"}\n\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,98 @@
+package stream
+
+import "context"
+
+// Record is one row of a feed read by a Tailer. TxID is the transaction that
+// wrote it. Publish is false for rows that should be passed over, e.g. a
+// created event whose chirp has since been deleted.
+type Record struct {
+	ID      int64
+	TxID    int64
+	Event   Event
+	Publish bool
+}
+
+// Source is a feed whose IDs are assigned when a row is written but which
+// only become visible when the writing transaction commits, so rows can
+// appear out of ID order.
+type Source interface {
+	// Horizon returns the oldest transaction still running. Every row that
+	// isn't visible yet was written by it or a later transaction.
+	Horizon(ctx context.Context) (int64, error)
+	// Since returns up to limit rows written by transaction minTxID or
+	// later with an ID above afterID, in ID order.
+	Since(ctx context.Context, minTxID, afterID int64, limit int) ([]Record, error)
+}
+
+// Tailer follows a Source, publishing each row once. IDs can't be used as a
+// cursor, because a transaction holding a lower ID can commit after one
+// holding a higher ID. Instead each poll rereads everything written by
+// transactions that were still running at the previous poll, and skips the
+// rows it has already seen.
+type Tailer struct {
+	src   Source
+	batch int
+
+	minTxID int64
+	// seen maps the IDs read since minTxID to their transactions.
+	seen map[int64]int64
+}
+
+// NewTailer returns a Tailer that publishes rows committed from now on.
+func NewTailer(ctx context.Context, src Source, batch int) (*Tailer, error) {
+	t := &Tailer{src: src, batch: batch, seen: map[int64]int64{}}
+	horizon, err := src.Horizon(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.minTxID = horizon
+	// Rows already visible are history, not news.
+	if err := t.scan(ctx, func(Event) {}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Poll passes rows that have become visible since the last poll to publish,
+// in ID order within each batch.
+func (t *Tailer) Poll(ctx context.Context, publish func(Event)) error {
+	// Taking the horizon first is conservative: anything still invisible
+	// during the scan belongs to a transaction no older than it.
+	horizon, err := t.src.Horizon(ctx)
+	if err != nil {
+		return err
+	}
+	if err := t.scan(ctx, publish); err != nil {
+		return err
+	}
+	t.minTxID = horizon
+	for id, txID := range t.seen {
+		if txID < horizon {
+			delete(t.seen, id)
+		}
+	}
+	return nil
+}
+
+func (t *Tailer) scan(ctx context.Context, publish func(Event)) error {
+	var afterID int64
+	for {
+		records, err := t.src.Since(ctx, t.minTxID, afterID, t.batch)
+		if err != nil {
+			return err
+		}
+		for _, r := range records {
+			afterID = r.ID
+			if _, ok := t.seen[r.ID]; ok {
+				continue
+			}
+			t.seen[r.ID] = r.TxID
+			if r.Publish {
+				publish(r.Event)
+			}
+		}
+		if len(records) < t.batch {
+			return nil
+		}
+	}
+}
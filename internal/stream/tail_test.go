@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// fakeFeed models a table with a sequence for IDs and MVCC visibility: rows
+// are only returned once their transaction has committed.
+type fakeFeed struct {
+	nextTx int64
+	nextID int64
+	active map[int64]bool
+	rows   []fakeRow
+}
+
+type fakeRow struct {
+	id, tx    int64
+	committed bool
+}
+
+func newFakeFeed() *fakeFeed {
+	return &fakeFeed{nextTx: 100, active: map[int64]bool{}}
+}
+
+func (f *fakeFeed) begin() int64 {
+	f.nextTx++
+	f.active[f.nextTx] = true
+	return f.nextTx
+}
+
+func (f *fakeFeed) insert(tx int64) int64 {
+	f.nextID++
+	f.rows = append(f.rows, fakeRow{id: f.nextID, tx: tx})
+	return f.nextID
+}
+
+func (f *fakeFeed) commit(tx int64) {
+	delete(f.active, tx)
+	for i := range f.rows {
+		if f.rows[i].tx == tx {
+			f.rows[i].committed = true
+		}
+	}
+}
+
+func (f *fakeFeed) Horizon(ctx context.Context) (int64, error) {
+	horizon := f.nextTx + 1
+	for tx := range f.active {
+		horizon = min(horizon, tx)
+	}
+	return horizon, nil
+}
+
+func (f *fakeFeed) Since(ctx context.Context, minTxID, afterID int64, limit int) ([]Record, error) {
+	var records []Record
+	for _, row := range f.rows {
+		if row.committed && row.tx >= minTxID && row.id > afterID && len(records) < limit {
+			records = append(records, Record{ID: row.id, TxID: row.tx, Event: Event{ID: row.id}, Publish: true})
+		}
+	}
+	return records, nil
+}
+
+func poll(t *testing.T, tailer *Tailer) []int64 {
+	t.Helper()
+	var got []int64
+	err := tailer.Poll(context.Background(), func(e Event) { got = append(got, e.ID) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestTailerSkipsExistingRows(t *testing.T) {
+	feed := newFakeFeed()
+	tx := feed.begin()
+	feed.insert(tx)
+	feed.commit(tx)
+
+	tailer, err := NewTailer(context.Background(), feed, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := poll(t, tailer); len(got) != 0 {
+		t.Errorf("got %v, want nothing", got)
+	}
+}
+
+func TestTailerInterleavedTransactions(t *testing.T) {
+	feed := newFakeFeed()
+	tailer, err := NewTailer(context.Background(), feed, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tx1 takes the lower ID but commits after tx2.
+	tx1 := feed.begin()
+	id1 := feed.insert(tx1)
+	tx2 := feed.begin()
+	id2 := feed.insert(tx2)
+	feed.commit(tx2)
+
+	if got := poll(t, tailer); !slices.Equal(got, []int64{id2}) {
+		t.Fatalf("first poll got %v, want [%d]", got, id2)
+	}
+
+	feed.commit(tx1)
+	tx3 := feed.begin()
+	id3 := feed.insert(tx3)
+	feed.commit(tx3)
+
+	if got := poll(t, tailer); !slices.Equal(got, []int64{id1, id3}) {
+		t.Errorf("second poll got %v, want [%d %d]", got, id1, id3)
+	}
+	if got := poll(t, tailer); len(got) != 0 {
+		t.Errorf("third poll got %v, want nothing", got)
+	}
+}
+
+func TestTailerPagesAndForgetsFinishedTransactions(t *testing.T) {
+	feed := newFakeFeed()
+	tailer, err := NewTailer(context.Background(), feed, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var want []int64
+	for range 5 {
+		tx := feed.begin()
+		want = append(want, feed.insert(tx))
+		feed.commit(tx)
+	}
+	if got := poll(t, tailer); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Every transaction had finished by the poll, so nothing needs to be
+	// remembered after the next one.
+	poll(t, tailer)
+	if len(tailer.seen) != 0 {
+		t.Errorf("seen has %d entries, want 0", len(tailer.seen))
+	}
+}
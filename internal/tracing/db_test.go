@@ -0,0 +1,35 @@
+package tracing
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "sqlc query",
+			query: "-- name: GetChirp :one\nSELECT id FROM chirps\nWHERE id = $1\n",
+			want:  "GetChirp",
+		},
+		{
+			name:  "plain query",
+			query: "SELECT 1",
+			want:  "query",
+		},
+		{
+			name:  "empty name",
+			query: "-- name: ",
+			want:  "query",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryName(tt.query); got != tt.want {
+				t.Errorf("queryName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type ctxKey struct{}
+
+func TestMiddlewareNamesSpanAfterRoute(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirp_id}", func(w http.ResponseWriter, r *http.Request) {})
+
+	// Like the server's logging middleware, this replaces the request to
+	// add to its context, so the ServeMux sets the pattern on a copy.
+	replacing := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, "x")))
+		})
+	}
+	handler := Middleware(replacing(Route(mux)))
+
+	tests := []struct {
+		path      string
+		wantName  string
+		wantRoute string
+	}{
+		{"/api/chirps/123", "GET /api/chirps/{chirp_id}", "GET /api/chirps/{chirp_id}"},
+		{"/nowhere", "http.request", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			recorder.Reset()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			if spans[0].Name() != tt.wantName {
+				t.Errorf("span name = %q, want %q", spans[0].Name(), tt.wantName)
+			}
+			var route string
+			for _, attr := range spans[0].Attributes() {
+				if attr.Key == attribute.Key("http.route") {
+					route = attr.Value.AsString()
+				}
+			}
+			if route != tt.wantRoute {
+				t.Errorf("http.route = %q, want %q", route, tt.wantRoute)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Package webhook sends signed outbound webhook requests.
+//
+// Each request carries the event type and delivery ID in headers, and an
+// X-Chirpy-Signature header of the form "t=<unix time>,v1=<hex>", where the
+// hex value is the HMAC-SHA256 of "<unix time>.<body>" keyed by the
+// webhook's secret. Receivers should recompute it and reject requests whose
+// timestamp is too old, which stops captured requests being replayed.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Chirpy-Signature"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+
+	// Timeout bounds a single delivery attempt, including reading the
+	// response.
+	Timeout = 10 * time.Second
+
+	// MaxAttempts is how many times a delivery is tried before it is given
+	// up on.
+	MaxAttempts = 8
+)
+
+var (
+	ErrBadSignature = errors.New("webhook: signature does not match")
+	ErrExpired      = errors.New("webhook: signature timestamp outside tolerance")
+)
+
+// NewSecret returns a random signing secret for a new webhook.
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(key), nil
+}
+
+// Sign returns the signature header value for body sent at t.
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+// Verify checks a signature header produced by Sign, rejecting it if its
+// timestamp is more than tolerance away from now.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrBadSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(mac(secret, ts, body))) {
+		return ErrBadSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return ErrExpired
+	}
+	return nil
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Backoff returns how long to wait before retrying after the given number
+// of failed attempts: 30s doubling each time, up to 6h.
+func Backoff(attempts int) time.Duration {
+	const maxDelay = 6 * time.Hour
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// Request is one attempt to deliver an event.
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID string
+	Event      string
+	Body       []byte
+}
+
+// Client sends webhook requests.
+type Client struct {
+	http *http.Client
+	now  func() time.Time
+}
+
+// NewClient returns a Client. Unless allowPrivate is set, it refuses to
+// connect to loopback, private and link-local addresses, so that webhook
+// URLs can't be used to reach services inside our network.
+func NewClient(allowPrivate bool) *Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = denyPrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Client{
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   Timeout,
+			// A redirect would skip the URL check the webhook was
+			// registered with.
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		now: time.Now,
+	}
+}
+
+// Send delivers req and returns the response status. Any status other than
+// 2xx is reported as an error alongside the status.
+func (c *Client) Send(ctx context.Context, req Request) (int, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	httpReq.Header.Set(EventHeader, req.Event)
+	httpReq.Header.Set(DeliveryHeader, req.DeliveryID)
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, c.now(), req.Body))
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook: endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func denyPrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("webhook: refusing to connect to %s", host)
+	}
+	return nil
+}
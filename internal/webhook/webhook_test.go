@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"chirp.created"}`)
+	header := Sign("secret", now, body)
+
+	if err := Verify("secret", header, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("Verify() = %v, want nil", err)
+	}
+	if err := Verify("other", header, body, now, 5*time.Minute); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("wrong secret: Verify() = %v, want ErrBadSignature", err)
+	}
+	if err := Verify("secret", header, []byte(`{}`), now, 5*time.Minute); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("changed body: Verify() = %v, want ErrBadSignature", err)
+	}
+	if err := Verify("secret", header, body, now.Add(time.Hour), 5*time.Minute); !errors.Is(err, ErrExpired) {
+		t.Errorf("old timestamp: Verify() = %v, want ErrExpired", err)
+	}
+	if err := Verify("secret", "garbage", body, now, 5*time.Minute); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("malformed header: Verify() = %v, want ErrBadSignature", err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{20, 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestClientSend(t *testing.T) {
+	var gotHeader http.Header
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	body := []byte(`{"type":"chirp.created"}`)
+	status, err := NewClient(true).Send(context.Background(), Request{
+		URL:        srv.URL,
+		Secret:     "secret",
+		DeliveryID: "d1",
+		Event:      "chirp.created",
+		Body:       body,
+	})
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Send() = %d, %v", status, err)
+	}
+	if gotHeader.Get(EventHeader) != "chirp.created" || gotHeader.Get(DeliveryHeader) != "d1" {
+		t.Errorf("missing event headers: %v", gotHeader)
+	}
+	if err := Verify("secret", gotHeader.Get(SignatureHeader), gotBody, time.Now(), time.Minute); err != nil {
+		t.Errorf("signature doesn't verify: %v", err)
+	}
+}
+
+func TestClientSendErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	status, err := NewClient(true).Send(context.Background(), Request{URL: srv.URL, Body: []byte(`{}`)})
+	if err == nil || status != http.StatusBadGateway {
+		t.Errorf("Send() = %d, %v; want 502 and an error", status, err)
+	}
+}
+
+func TestClientDeniesPrivate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	if _, err := NewClient(false).Send(context.Background(), Request{URL: srv.URL, Body: []byte(`{}`)}); err == nil {
+		t.Error("Send() to loopback succeeded, want error")
+	}
+}
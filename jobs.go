@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/jobs"
+)
+
+const (
+	succeededJobRetention = 7 * 24 * time.Hour
+	deadJobRetention = 30 * 24 * time.Hour
+)
+
+var jobPruneJobs = jobs.Kind[struct{}]{Name: "cleanup.jobs"}
+
+// jobStore keeps the job queue in the jobs table.
+type jobStore struct {
+	db *database.Queries
+}
+
+func(s jobStore) Enqueue(ctx context.Context, kind string, payload json.RawMessage, maxAttempts int, uniqueKey string) error {
+	return s.db.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind: kind,
+		Payload: payload,
+		MaxAttempts: int32(maxAttempts),
+		UniqueKey: sql.NullString{String: uniqueKey, Valid: uniqueKey != ""},
+	})
+}
+
+func(s jobStore) Claim(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]jobs.Job, error) {
+	rows, err := s.db.ClaimJobs(ctx, database.ClaimJobsParams{
+		LeaseSeconds: lease.Seconds(),
+		Kinds: kinds,
+		BatchSize: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	claimed := make([]jobs.Job, 0, len(rows))
+	for _, row := range rows {
+		claimed = append(claimed, jobs.Job{
+			ID: row.ID,
+			Kind: row.Kind,
+			Payload: row.Payload,
+			Attempt: int(row.Attempts),
+			MaxAttempts: int(row.MaxAttempts),
+		})
+	}
+	return claimed, nil
+}
+
+func(s jobStore) Complete(ctx context.Context, job jobs.Job) error {
+	n, err := s.db.CompleteJob(ctx, database.CompleteJobParams{
+		ID: job.ID,
+		Attempt: int32(job.Attempt),
+	})
+	return leaseHeld(n, err)
+}
+
+func(s jobStore) Retry(ctx context.Context, job jobs.Job, delay time.Duration, reason string) error {
+	n, err := s.db.RetryJob(ctx, database.RetryJobParams{
+		ID: job.ID,
+		Attempt: int32(job.Attempt),
+		DelaySeconds: delay.Seconds(),
+		LastError: sql.NullString{String: truncate(reason, 1000), Valid: true},
+	})
+	return leaseHeld(n, err)
+}
+
+func(s jobStore) Bury(ctx context.Context, job jobs.Job, reason string) error {
+	n, err := s.db.BuryJob(ctx, database.BuryJobParams{
+		ID: job.ID,
+		Attempt: int32(job.Attempt),
+		LastError: sql.NullString{String: truncate(reason, 1000), Valid: true},
+	})
+	return leaseHeld(n, err)
+}
+
+// leaseHeld turns an update that matched no rows into jobs.ErrLeaseLost.
+func leaseHeld(rows int64, err error) error {
+	if err == nil && rows == 0 {
+		return jobs.ErrLeaseLost
+	}
+	return err
+}
+
+// enqueueJob queues a job of kind k to run after delay. Pass a
+// transaction's queries to only queue it if the transaction commits.
+func enqueueJob[T any](ctx context.Context, q *database.Queries, k jobs.Kind[T], args T, delay time.Duration) error {
+	payload, maxAttempts, err := k.Encode(args)
+	if err != nil {
+		return err
+	}
+	return q.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind: k.Name,
+		Payload: payload,
+		MaxAttempts: int32(maxAttempts),
+		DelaySeconds: delay.Seconds(),
+	})
+}
+
+// newJobPool returns a pool with a handler for every kind of job chirpy
+// queues.
+func(cfg *apiConfig) newJobPool(workers int) *jobs.Pool {
+	pool := jobs.NewPool(jobStore{db: cfg.db}, workers)
+	pool.OnDone = func(job jobs.Job, outcome jobs.Outcome, elapsed time.Duration) {
+		cfg.metrics.jobs.WithLabelValues(job.Kind, string(outcome)).Inc()
+		cfg.metrics.jobDuration.WithLabelValues(job.Kind).Observe(elapsed.Seconds())
+	}
+
+	jobs.Handle(pool, jobDeliverWebhook, cfg.webhooks.deliver)
+	jobs.Handle(pool, jobNotifyMessage, cfg.notifyMessage)
+	jobs.Handle(pool, jobPublishChirp, cfg.publishScheduledChirp)
+	jobs.Handle(pool, jobPruneChirpEvents, cfg.pruneChirpEvents)
+	jobs.Handle(pool, jobPruneJobs, cfg.pruneJobs)
+	jobs.Handle(pool, jobPruneRefreshTokens, cfg.pruneRefreshTokensJob)
+
+	jobs.Every(pool, jobPruneChirpEvents, time.Hour)
+	jobs.Every(pool, jobPruneJobs, 24*time.Hour)
+	jobs.Every(pool, jobPruneRefreshTokens, time.Hour)
+	return pool
+}
+
+func(cfg *apiConfig) pruneJobs(ctx context.Context, job jobs.Job, _ struct{}) error {
+	_, err := cfg.db.DeleteFinishedJobsOlderThan(ctx, database.DeleteFinishedJobsOlderThanParams{
+		SucceededAgeSeconds: succeededJobRetention.Seconds(),
+		DeadAgeSeconds: deadJobRetention.Seconds(),
+	})
+	return err
+}
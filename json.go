@@ -2,22 +2,28 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 )
 
-func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
-	}
+// respondWithError logs server errors, and client errors with a cause, with
+// the request's context so the lines carry its request and user IDs.
+func respondWithError(w http.ResponseWriter, r *http.Request, code int, msg string, err error) {
+	// The logging middleware has already set the request ID header, which
+	// lets error responses and log lines be tied back to the request.
+	requestID := w.Header().Get("X-Request-ID")
 	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+		slog.ErrorContext(r.Context(), "Responding with 5XX error", "response", msg, "status", code, "error", err)
+	} else if err != nil {
+		slog.InfoContext(r.Context(), "Responding with error", "response", msg, "status", code, "error", err)
 	}
 	type errorResponse struct {
-		Error string `json:"error"`
+		Error		string	`json:"error"`
+		RequestID	string	`json:"request_id,omitempty"`
 	}
 	respondWithJSON(w, code, errorResponse{
 		Error: msg,
+		RequestID: requestID,
 	})
 }
 
@@ -25,10 +31,10 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		slog.Error("Error marshalling JSON", "error", err, "request_id", w.Header().Get("X-Request-ID"))
 		w.WriteHeader(500)
 		return
 	}
 	w.WriteHeader(code)
 	w.Write(dat)
-}
\ No newline at end of file
+}
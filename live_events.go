@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/jobs"
+	"github.com/ppllama/chirpy/internal/stream"
+)
+
+const (
+	chirpEventsChannel = "chirp_events"
+	liveEventsChannel = "live_events"
+	chirpEventsBatch = 500
+	chirpEventRetention = 24 * time.Hour
+)
+
+// Broker topics. Chirp events go to the timeline; messages and notifications
+// go to a topic per conversation and per recipient.
+const topicTimeline = "timeline"
+
+func conversationTopic(id uuid.UUID) string {
+	return "conversation:" + id.String()
+}
+
+func notificationsTopic(userID uuid.UUID) string {
+	return "notifications:" + userID.String()
+}
+
+// chirpEventFromDB converts a chirp_events row to what streams send. It
+// returns false for a created event whose chirp has since been deleted or
+// hidden; a deleted event follows it anyway.
+func chirpEventFromDB(row database.ListChirpEventsRow) (stream.Event, bool) {
+	event := stream.Event{
+		ID: row.ID,
+		Topic: topicTimeline,
+		AuthorID: row.UserID,
+		AuthorLimited: row.AuthorLimited,
+	}
+
+	var data any
+	switch row.Type {
+	case "created":
+		if !row.ChirpBody.Valid {
+			return stream.Event{}, false
+		}
+		event.Type = "chirp.created"
+		data = Chirp{
+			ID: row.ChirpID,
+			CreatedAt: row.ChirpCreatedAt.Time,
+			UpdatedAt: row.ChirpUpdatedAt.Time,
+			Body: row.ChirpBody.String,
+			UserID: row.UserID,
+		}
+	default:
+		event.Type = "chirp.deleted"
+		data = struct {
+			ID		uuid.UUID	`json:"id"`
+			UserID	uuid.UUID	`json:"user_id"`
+		}{row.ChirpID, row.UserID}
+	}
+
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return stream.Event{}, false
+	}
+	event.Data = dat
+	return event, true
+}
+
+// liveEventFromDB loads the message or notification a live_events NOTIFY
+// refers to. Unlike chirp events these aren't kept for replay; clients
+// refetch over the REST API after reconnecting.
+func liveEventFromDB(ctx context.Context, db *database.Queries, payload string) (stream.Event, error) {
+	var ref struct {
+		Type	string		`json:"type"`
+		ID		uuid.UUID	`json:"id"`
+	}
+	if err := json.Unmarshal([]byte(payload), &ref); err != nil {
+		return stream.Event{}, err
+	}
+
+	var event stream.Event
+	var data any
+	switch ref.Type {
+	case "message":
+		message, err := db.GetMessage(ctx, ref.ID)
+		if err != nil {
+			return stream.Event{}, err
+		}
+		event = stream.Event{
+			Topic: conversationTopic(message.ConversationID),
+			Type: "message.created",
+			AuthorID: message.SenderID.UUID,
+		}
+		data = messageFromDB(message)
+	case "notification":
+		n, err := db.GetNotification(ctx, ref.ID)
+		if err != nil {
+			return stream.Event{}, err
+		}
+		event = stream.Event{
+			Topic: notificationsTopic(n.UserID),
+			Type: "notification.created",
+			AuthorID: n.ActorID.UUID,
+		}
+		data = struct {
+			ID			string		`json:"id"`
+			Type		string		`json:"type"`
+			SubjectType	string		`json:"subject_type"`
+			SubjectID	string		`json:"subject_id"`
+			ActorID		*uuid.UUID	`json:"actor_id"`
+			CreatedAt	time.Time	`json:"created_at"`
+		}{n.GroupKey, n.Type, n.SubjectType, n.SubjectID, nullableUUID(n.ActorID), n.CreatedAt}
+	default:
+		return stream.Event{}, fmt.Errorf("unknown live event type %q", ref.Type)
+	}
+
+	dat, err := json.Marshal(data)
+	if err != nil {
+		return stream.Event{}, err
+	}
+	event.Data = dat
+	return event, nil
+}
+
+func nullableUUID(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+// chirpEventSource lets a stream.Tailer follow chirp_events.
+type chirpEventSource struct {
+	db *database.Queries
+}
+
+func(s chirpEventSource) Horizon(ctx context.Context) (int64, error) {
+	return s.db.GetTxHorizon(ctx)
+}
+
+func(s chirpEventSource) Since(ctx context.Context, minTxID, afterID int64, limit int) ([]stream.Record, error) {
+	rows, err := s.db.ListChirpEvents(ctx, database.ListChirpEventsParams{
+		AfterID: afterID,
+		MinTxID: minTxID,
+		Limit: int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	records := make([]stream.Record, 0, len(rows))
+	for _, row := range rows {
+		event, ok := chirpEventFromDB(row)
+		records = append(records, stream.Record{ID: row.ID, TxID: row.TxID, Event: event, Publish: ok})
+	}
+	return records, nil
+}
+
+// listenEvents feeds broker until ctx is done. Each instance LISTENs for the
+// NOTIFYs sent by the chirps, messages and notifications triggers, so events
+// written through any instance reach every subscriber. For chirps it then
+// polls chirp_events for everything that has committed since, so nothing is
+// lost across reconnects or to transactions committing out of id order.
+func listenEvents(ctx context.Context, dbURL string, db *database.Queries, broker *stream.Broker) error {
+	tailer, err := stream.NewTailer(ctx, chirpEventSource{db: db}, chirpEventsBatch)
+	if err != nil {
+		return err
+	}
+
+	listener := pq.NewListener(dbURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Warn("Chirp event listener", "event", event, "error", err)
+		}
+	})
+	for _, channel := range []string{chirpEventsChannel, liveEventsChannel} {
+		if err := listener.Listen(channel); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	go func() {
+		defer listener.Close()
+
+		catchUp := func() {
+			if err := tailer.Poll(ctx, broker.Publish); err != nil && ctx.Err() == nil {
+				slog.Error("Failed to read chirp events", "error", err)
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			// A nil notification means the connection was re-established;
+			// catching up covers anything sent while it was down.
+			case n := <-listener.Notify:
+				if n != nil && n.Channel == liveEventsChannel {
+					event, err := liveEventFromDB(ctx, db, n.Extra)
+					if err != nil {
+						slog.Error("Failed to load live event", "payload", n.Extra, "error", err)
+						continue
+					}
+					broker.Publish(event)
+					continue
+				}
+				catchUp()
+			case <-time.After(90 * time.Second):
+				go listener.Ping()
+				catchUp()
+			}
+		}
+	}()
+	return nil
+}
+
+var jobPruneChirpEvents = jobs.Kind[struct{}]{Name: "cleanup.chirp_events"}
+
+// pruneChirpEvents deletes chirp events too old to be replayed.
+func(cfg *apiConfig) pruneChirpEvents(ctx context.Context, job jobs.Job, _ struct{}) error {
+	_, err := cfg.db.DeleteChirpEventsOlderThan(ctx, chirpEventRetention.Seconds())
+	return err
+}
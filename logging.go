@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/ppllama/chirpy/internal/logging"
+)
+
+// middlewareLogging assigns every request an ID (taken from X-Request-ID when
+// the client sends a usable one), echoes it back in the response headers and
+// writes an access log line once the request has been handled.
+func(cfg *apiConfig) middlewareLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := logging.RequestIDFromHeader(r.Header.Get("X-Request-ID"))
+		w.Header().Set("X-Request-ID", requestID)
+		r = r.WithContext(logging.NewContext(r.Context(), requestID))
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		slog.InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"route", routeLabel(r),
+			"path", r.URL.Path,
+			"status", rec.statusCode(),
+			"latency", time.Since(start),
+		)
+	})
+}
@@ -1,75 +1,327 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"sync/atomic"
+	"syscall"
+	"time"
 
-	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/ppllama/chirpy/internal/auth"
+	"github.com/ppllama/chirpy/internal/config"
 	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/filter"
+	"github.com/ppllama/chirpy/internal/logging"
+	"github.com/ppllama/chirpy/internal/migrate"
+	"github.com/ppllama/chirpy/internal/stream"
+	"github.com/ppllama/chirpy/internal/tracing"
+	"github.com/ppllama/chirpy/internal/webhook"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db *database.Queries
+	dbConn *sql.DB
+	migrator *migrate.Migrator
 	platform string
 	secret string
 	polka_key string
+	refreshTokenRetention time.Duration
+	metrics *promMetrics
+	filter *filter.Reloader
+	stream *stream.Broker
+	wsConns wsConnections
+	webhooks *webhookSender
+	draining atomic.Bool
+}
+
+const usage = `Usage: chirpy [command] [flags]
+
+Commands:
+  serve                           Run the HTTP server (default)
+  migrate up|down|status|version  Manage the database schema
+  create-user -email E [-role R]  Create a user (password from -password or stdin)
+  set-role -email E -role R       Make a user a user, moderator or admin
+  set-status -email E -status S   Make a user active, limited or suspended
+                                  (-reason R, -for D to lift automatically)
+  set-password -email E           Reset a user's password
+  grant-red -email E              Give a user Chirpy Red
+  revoke-sessions -email E        Revoke all of a user's refresh tokens
+  delete-user -email E -yes       Delete a user and everything they own
+  list-users [-limit N]           List users
+  export-user -email E            Print everything stored about a user as JSON
+  prune-tokens [-retention D]     Delete expired and revoked refresh tokens
+
+Commands that take -email also accept -id.
+`
+
+var commands = map[string]func(*config.Config, []string) error{
+	"serve": func(appConfig *config.Config, _ []string) error { return serve(appConfig) },
+	"migrate": runMigrate,
+	"create-user": runCreateUser,
+	"set-status": runSetStatus,
+	"set-password": runSetPassword,
+	"set-role": runSetRole,
+	"grant-red": runGrantRed,
+	"revoke-sessions": runRevokeSessions,
+	"delete-user": runDeleteUser,
+	"list-users": runListUsers,
+	"export-user": runExportUser,
+	"prune-tokens": runPruneTokens,
 }
 
 func main() {
-	const filePathRoot = "."
-	const port = ":8080"
+	appConfig, err := config.Load()
+	if err != nil {
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
 
-	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Fatal("DB_URL must be set")
+	logger, err := logging.New(os.Stderr, appConfig.LogFormat, appConfig.LogLevel)
+	if err != nil {
+		slog.Error("failed to set up logging", "error", err)
+		os.Exit(1)
 	}
-	platform := os.Getenv("PLATFORM")
-	JWT_Secret := os.Getenv("JWT_SECRET")
-	POLKA_KEY := os.Getenv("POLKA_KEY")
+	slog.SetDefault(logger)
 
-	dbConn, err := sql.Open("postgres", dbURL)
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	run, ok := commands[command]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := run(appConfig, args); err != nil {
+		slog.Error("command failed", "command", command, "error", err)
+		os.Exit(1)
+	}
+}
+
+func serve(appConfig *config.Config) error {
+	slog.Info("Loaded configuration", "config", appConfig)
+
+	shutdownTracing, err := tracing.Setup(context.Background(), appConfig.TracesExporter)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	dbConn, err := sql.Open("postgres", appConfig.DBURL)
 	if err != nil {
-		log.Fatalf("failed to open db: %v", err)
+		return fmt.Errorf("failed to open db: %w", err)
 	}
+	defer dbConn.Close()
 
-	dbQueries := database.New(dbConn)
+	migrator, err := migrate.New(dbConn)
+	if err != nil {
+		return err
+	}
+	if appConfig.AutoMigrate {
+		results, err := migrator.Up(context.Background())
+		if err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		for _, result := range results {
+			slog.Info("Applied migration", "version", result.Source.Version, "duration", result.Duration)
+		}
+	}
+	// Refuse to serve against a schema this build doesn't understand.
+	if err := migrator.Check(context.Background()); err != nil {
+		return err
+	}
+
+	dbQueries := database.New(tracing.WrapDB(dbConn))
 	cfg := &apiConfig{
 		fileserverHits: atomic.Int32{},
 		db: dbQueries,
-		platform: platform,
-		secret: JWT_Secret,
-		polka_key: POLKA_KEY,
+		dbConn: dbConn,
+		migrator: migrator,
+		platform: appConfig.Platform,
+		secret: appConfig.JWTSecret,
+		polka_key: appConfig.PolkaKey,
+		refreshTokenRetention: appConfig.RefreshTokenRetention,
+		metrics: newPromMetrics(dbConn),
+		filter: filter.NewReloader(filterSource(dbQueries, appConfig.FilterFile)),
+		stream: stream.NewBroker(64),
+	}
+	cfg.metrics.registerStream(cfg.stream)
+	cfg.webhooks = &webhookSender{
+		db: dbQueries,
+		// Local endpoints are handy in development but must not be
+		// reachable otherwise.
+		client: webhook.NewClient(cfg.platform == "dev"),
+		metrics: cfg.metrics,
+	}
+
+	if err := cfg.filter.Reload(context.Background()); err != nil {
+		return fmt.Errorf("failed to load content filter: %w", err)
+	}
+	// Reload periodically so rule changes made on other instances or in
+	// FILTER_FILE take effect, and on SIGHUP to apply them immediately.
+	filterCtx, stopFilter := context.WithCancel(context.Background())
+	defer stopFilter()
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	go cfg.filter.Watch(filterCtx, appConfig.FilterReloadInterval, hup)
+
+	streamCtx, stopStream := context.WithCancel(context.Background())
+	defer stopStream()
+	if err := listenEvents(streamCtx, appConfig.DBURL, dbQueries, cfg.stream); err != nil {
+		return fmt.Errorf("failed to listen for events: %w", err)
 	}
 
+	jobPool := cfg.newJobPool(appConfig.JobWorkers)
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		jobPool.Run(jobsCtx)
+	}()
+
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
-	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
+	mux.HandleFunc("GET /api/healthz", cfg.handlerReadiness)
+	mux.HandleFunc("GET /api/livez", handlerLiveness)
+	mux.HandleFunc("GET /api/readyz", cfg.handlerReadyz)
+	mux.Handle("GET /metrics", cfg.metrics.handler())
+	mux.Handle("GET /admin/metrics", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.handlerMetrics))
+	mux.Handle("POST /admin/reset", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.handlerReset))
+	mux.Handle("GET /admin/audit", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.handlerListAuditEvents))
+	mux.Handle("GET /admin/reports", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerListReports))
+	mux.Handle("GET /admin/reports/{report_id}", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerGetReport))
+	mux.Handle("POST /admin/reports/{report_id}/claim", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerClaimReport))
+	mux.Handle("POST /admin/reports/{report_id}/resolve", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerResolveReport))
+	mux.Handle("POST /admin/reports/{report_id}/notes", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerAddReportNote))
+	mux.Handle("PUT /admin/users/{user_id}/status", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerSetUserStatus))
+	mux.Handle("GET /admin/filter/rules", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerListFilterRules))
+	mux.Handle("PUT /admin/filter/rules/{word}", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerPutFilterRule))
+	mux.Handle("DELETE /admin/filter/rules/{word}", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerDeleteFilterRule))
+	mux.Handle("POST /admin/filter/reload", cfg.middlewareRequireRole(auth.RoleModerator, cfg.handlerReloadFilter))
+	mux.Handle("GET /admin/jobs", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.handlerListJobs))
+	mux.Handle("GET /admin/jobs/stats", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.handlerJobStats))
+	mux.Handle("GET /admin/jobs/{job_id}", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.handlerGetJob))
+	mux.Handle("POST /admin/jobs/{job_id}/retry", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.handlerRetryJob))
+	mux.Handle("DELETE /admin/jobs/{job_id}", cfg.middlewareRequireRole(auth.RoleAdmin, cfg.handlerDeleteJob))
 	mux.HandleFunc("POST /api/chirps", cfg.handlerPostChirps)
 	mux.HandleFunc("GET /api/chirps", cfg.handlerGetChirps)
 	mux.HandleFunc("GET /api/chirps/{chirp_id}", cfg.handlerChirp)
+	mux.Handle("GET /api/chirps/scheduled", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListScheduledChirps))
+	mux.Handle("PUT /api/chirps/scheduled/{scheduled_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerUpdateScheduledChirp))
+	mux.Handle("DELETE /api/chirps/scheduled/{scheduled_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerCancelScheduledChirp))
 	mux.HandleFunc("DELETE /api/chirps/{chirp_id}", cfg.handlerDeleteChirp)
+	mux.Handle("POST /api/chirps/{chirp_id}/vote", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerVote))
+	mux.Handle("GET /api/bookmarks", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListBookmarks))
+	mux.Handle("PUT /api/bookmarks/{chirp_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerAddBookmark))
+	mux.Handle("DELETE /api/bookmarks/{chirp_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerRemoveBookmark))
+	mux.Handle("POST /api/bookmarks/collections", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerCreateBookmarkCollection))
+	mux.Handle("GET /api/bookmarks/collections", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListBookmarkCollections))
+	mux.Handle("PUT /api/bookmarks/collections/{collection_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerRenameBookmarkCollection))
+	mux.Handle("DELETE /api/bookmarks/collections/{collection_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerDeleteBookmarkCollection))
+	mux.Handle("POST /api/drafts", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerCreateDraft))
+	mux.Handle("GET /api/drafts", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListDrafts))
+	mux.Handle("GET /api/drafts/{draft_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerGetDraft))
+	mux.Handle("PUT /api/drafts/{draft_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerUpdateDraft))
+	mux.Handle("DELETE /api/drafts/{draft_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerDeleteDraft))
+	mux.Handle("POST /api/drafts/{draft_id}/publish", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerPublishDraft))
+	mux.HandleFunc("GET /api/stream", cfg.handlerStream)
+	mux.HandleFunc("GET /api/ws", cfg.handlerWebSocket)
 	mux.HandleFunc("POST /api/users", cfg.handlerUsers)
 	mux.HandleFunc("POST /api/login", cfg.handlerLogin)
 	mux.HandleFunc("POST /api/refresh", cfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
 	mux.HandleFunc("PUT /api/users", cfg.handlerUpdateUser)
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlerUpgradeUser)
-	mux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filePathRoot)))))
+	mux.Handle("POST /api/reports", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerCreateReport))
+	mux.Handle("GET /api/reports", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListMyReports))
+	mux.Handle("POST /api/blocks", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerCreateRelationship(relationshipBlock)))
+	mux.Handle("GET /api/blocks", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListRelationships(relationshipBlock)))
+	mux.Handle("DELETE /api/blocks/{user_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerDeleteRelationship(relationshipBlock)))
+	mux.Handle("POST /api/mutes", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerCreateRelationship(relationshipMute)))
+	mux.Handle("GET /api/mutes", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListRelationships(relationshipMute)))
+	mux.Handle("DELETE /api/mutes/{user_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerDeleteRelationship(relationshipMute)))
+	mux.Handle("POST /api/conversations", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerCreateConversation))
+	mux.Handle("GET /api/conversations", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListConversations))
+	mux.Handle("GET /api/conversations/{conversation_id}/messages", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListMessages))
+	mux.Handle("POST /api/conversations/{conversation_id}/messages", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerSendMessage))
+	mux.Handle("POST /api/conversations/{conversation_id}/read", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerMarkConversationRead))
+	mux.Handle("GET /api/messages/unread", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerUnreadMessages))
+	mux.Handle("GET /api/notifications", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListNotifications))
+	mux.Handle("GET /api/notifications/unread", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerUnreadNotifications))
+	mux.Handle("POST /api/notifications/read", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerMarkAllNotificationsRead))
+	mux.Handle("POST /api/notifications/{notification_id}/read", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerMarkNotificationRead))
+	mux.Handle("GET /api/notifications/preferences", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerGetNotificationPreferences))
+	mux.Handle("PUT /api/notifications/preferences", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerUpdateNotificationPreferences))
+	mux.Handle("POST /api/webhooks", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerCreateWebhook))
+	mux.Handle("GET /api/webhooks", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListWebhooks))
+	mux.Handle("GET /api/webhooks/{webhook_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerGetWebhook))
+	mux.Handle("PUT /api/webhooks/{webhook_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerUpdateWebhook))
+	mux.Handle("DELETE /api/webhooks/{webhook_id}", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerDeleteWebhook))
+	mux.Handle("GET /api/webhooks/{webhook_id}/deliveries", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerListWebhookDeliveries))
+	mux.Handle("POST /api/webhooks/{webhook_id}/test", cfg.middlewareRequireRole(auth.RoleUser, cfg.handlerTestWebhook))
+	mux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(appConfig.FileRoot)))))
 
 	server := &http.Server{
-		Addr: port,
-		Handler: mux,
+		Addr: appConfig.Addr(),
+		Handler: tracing.Middleware(cfg.middlewareLogging(cfg.middlewareMetrics(tracing.Route(mux)))),
+		ReadTimeout: appConfig.ReadTimeout,
+		ReadHeaderTimeout: appConfig.ReadHeaderTimeout,
+		WriteTimeout: appConfig.WriteTimeout,
+		IdleTimeout: appConfig.IdleTimeout,
 	}
+	// Shutdown waits for in-flight requests, so end open streams rather
+	// than have them hold it up until the timeout.
+	server.RegisterOnShutdown(cfg.stream.Close)
 
-	log.Printf("Serving on port: %s\n", port)
-	log.Fatal(server.ListenAndServe())
-}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Serving", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	// Restore default signal handling so a second signal kills the process
+	// straight away.
+	stop()
+
+	// Fail readiness first and give load balancers a moment to notice
+	// before we stop accepting connections.
+	cfg.draining.Store(true)
+	slog.Info("Draining", "delay", appConfig.DrainDelay)
+	time.Sleep(appConfig.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), appConfig.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+
+	// Let jobs in progress finish.
+	stopJobs()
+	<-jobsDone
+
+	slog.Info("Shut down")
+	return nil
+}
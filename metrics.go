@@ -1,14 +1,171 @@
 package main
 
 import(
-	"net/http"
+	"database/sql"
 	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/ppllama/chirpy/internal/stream"
 )
 
+type promMetrics struct {
+	registry		*prometheus.Registry
+	requests		*prometheus.CounterVec
+	requestDuration	*prometheus.HistogramVec
+	fileserverHits	prometheus.Counter
+	logins			*prometheus.CounterVec
+	chirpsCreated	prometheus.Counter
+	webhooks		*prometheus.CounterVec
+	filterMatches	*prometheus.CounterVec
+	outboundWebhooks	*prometheus.CounterVec
+	jobs			*prometheus.CounterVec
+	jobDuration		*prometheus.HistogramVec
+	refreshTokensPruned	prometheus.Counter
+}
+
+func newPromMetrics(db *sql.DB) *promMetrics {
+	m := &promMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests handled, by route pattern and status code.",
+		}, []string{"route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "chirpy_http_request_duration_seconds",
+			Help: "HTTP request latency, by route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "code"}),
+		fileserverHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests served from /app/.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts, by result.",
+		}, []string{"result"}),
+		chirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created.",
+		}),
+		webhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhooks_total",
+			Help: "Inbound webhooks, by event and outcome.",
+		}, []string{"event", "outcome"}),
+		filterMatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_filter_matches_total",
+			Help: "Words matched by the content filter, by action.",
+		}, []string{"action"}),
+		outboundWebhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_deliveries_total",
+			Help: "Outbound webhook delivery attempts, by outcome.",
+		}, []string{"outcome"}),
+		jobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_jobs_total",
+			Help: "Background job attempts, by kind and outcome.",
+		}, []string{"kind", "outcome"}),
+		jobDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "chirpy_job_duration_seconds",
+			Help: "Background job attempt duration, by kind.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"kind"}),
+		refreshTokensPruned: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_refresh_tokens_deleted_total",
+			Help: "Expired and revoked refresh tokens deleted by cleanup.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.fileserverHits,
+		m.logins,
+		m.chirpsCreated,
+		m.webhooks,
+		m.filterMatches,
+		m.outboundWebhooks,
+		m.jobs,
+		m.jobDuration,
+		m.refreshTokensPruned,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "chirpy"),
+	)
+	return m
+}
+
+func(m *promMetrics) registerStream(broker *stream.Broker) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "chirpy_stream_subscribers",
+		Help: "Open /api/stream and /api/ws connections.",
+	}, func() float64 { return float64(broker.Len()) }))
+}
+
+func(m *promMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// statusRecorder captures the status code written by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func(rec *statusRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func(rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func(rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func(rec *statusRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// routeLabel returns the ServeMux pattern that matched r, which the mux
+// records on the request while routing it.
+func routeLabel(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	return r.Pattern
+}
+
+func(cfg *apiConfig) middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := routeLabel(r)
+		code := strconv.Itoa(rec.statusCode())
+		cfg.metrics.requests.WithLabelValues(route, code).Inc()
+		cfg.metrics.requestDuration.WithLabelValues(route, code).Observe(time.Since(start).Seconds())
+	})
+}
 
 func(cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverHits.Add(1)
+		cfg.metrics.fileserverHits.Inc()
 		next.ServeHTTP(w, r)
 	})
 }
@@ -23,4 +180,4 @@ func(cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
   </body>
 </html>`,
 	cfg.fileserverHits.Load())))
-}
\ No newline at end of file
+}
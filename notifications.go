@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+// Notification types. Each type needs a summary, which describes a group of
+// notifications of that type about the same subject.
+const (
+	notificationMessage = "message"
+	notificationReportResolved = "report_resolved"
+)
+
+var notificationSummaries = map[string]func(count int64, actors int) string{
+	notificationMessage: func(count int64, actors int) string {
+		switch {
+		case count == 1:
+			return "New message"
+		case actors > 1:
+			return fmt.Sprintf("%d new messages from %d people", count, actors)
+		default:
+			return fmt.Sprintf("%d new messages", count)
+		}
+	},
+	notificationReportResolved: func(count int64, actors int) string {
+		return "Your report was reviewed"
+	},
+}
+
+type notification struct {
+	UserID		uuid.UUID
+	Type		string
+	ActorID		uuid.UUID
+	SubjectType	string
+	SubjectID	string
+}
+
+// groupKey identifies the subject a notification is about, so that e.g. all
+// unread messages in one conversation are shown as a single entry.
+func(n notification) groupKey() string {
+	return n.Type + ":" + n.SubjectType + ":" + n.SubjectID
+}
+
+// notify records a notification unless the recipient has turned its type off
+// or has blocked the actor. Like recordAudit, failures are logged rather than
+// failing the action that caused them.
+func notify(ctx context.Context, db *database.Queries, n notification) {
+	_, err := db.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID: n.UserID,
+		Type: n.Type,
+		ActorID: uuid.NullUUID{UUID: n.ActorID, Valid: n.ActorID != uuid.Nil},
+		SubjectType: n.SubjectType,
+		SubjectID: n.SubjectID,
+		GroupKey: n.groupKey(),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to record notification", "type", n.Type, "error", err)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/jobs"
+	"github.com/ppllama/chirpy/internal/webhook"
+)
+
+// Outbound webhook event types. webhookTest is only sent by the test-fire
+// endpoint and can't be subscribed to.
+const (
+	webhookChirpCreated = "chirp.created"
+	webhookChirpDeleted = "chirp.deleted"
+	webhookUserUpgraded = "user.upgraded"
+	webhookTest = "webhook.test"
+)
+
+var webhookEvents = []string{webhookChirpCreated, webhookChirpDeleted, webhookUserUpgraded}
+
+type deliverWebhookArgs struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+var jobDeliverWebhook = jobs.Kind[deliverWebhookArgs]{
+	Name: "webhook.deliver",
+	MaxAttempts: webhook.MaxAttempts,
+	Timeout: webhook.Timeout + 5*time.Second,
+	Backoff: webhook.Backoff,
+}
+
+type webhookEvent struct {
+	Type	string
+	// SubjectUserID is who the event is about. Besides global webhooks,
+	// only that user's webhooks receive it.
+	SubjectUserID	uuid.UUID
+	Data	any
+}
+
+// webhookPayload is the JSON body of every outbound webhook request.
+type webhookPayload struct {
+	ID			uuid.UUID	`json:"id"`
+	Type		string		`json:"type"`
+	CreatedAt	time.Time	`json:"created_at"`
+	Data		any			`json:"data"`
+}
+
+// chirpDeletedEvent is sent when a chirp is deleted by its author, or hidden
+// by a moderator, which to integrations amounts to the same thing.
+func chirpDeletedEvent(chirpID, authorID uuid.UUID, reason string) webhookEvent {
+	return webhookEvent{
+		Type: webhookChirpDeleted,
+		SubjectUserID: authorID,
+		Data: map[string]any{"id": chirpID, "user_id": authorID, "reason": reason},
+	}
+}
+
+func(e webhookEvent) payload() (uuid.UUID, []byte, error) {
+	id := uuid.New()
+	dat, err := json.Marshal(webhookPayload{
+		ID: id,
+		Type: e.Type,
+		CreatedAt: time.Now().UTC(),
+		Data: e.Data,
+	})
+	return id, dat, err
+}
+
+// enqueueWebhook queues the event for every webhook subscribed to it. Call it
+// inside the transaction that makes the change, so that the event is sent if
+// and only if the change is committed.
+func enqueueWebhook(ctx context.Context, q *database.Queries, e webhookEvent) error {
+	id, dat, err := e.payload()
+	if err != nil {
+		return err
+	}
+	deliveryIDs, err := q.EnqueueWebhookEvent(ctx, database.EnqueueWebhookEventParams{
+		EventID: id,
+		EventType: e.Type,
+		Payload: dat,
+		SubjectUserID: e.SubjectUserID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, deliveryID := range deliveryIDs {
+		if err := enqueueJob(ctx, q, jobDeliverWebhook, deliverWebhookArgs{DeliveryID: deliveryID}, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type webhookSender struct {
+	db		*database.Queries
+	client	*webhook.Client
+	metrics	*promMetrics
+}
+
+// deliver runs a webhook.deliver job. The job queue takes care of retries;
+// the delivery row records each attempt for the delivery log.
+func(ws *webhookSender) deliver(ctx context.Context, job jobs.Job, args deliverWebhookArgs) error {
+	d, err := ws.db.GetWebhookDeliveryTarget(ctx, args.DeliveryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The webhook has been deleted since.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var retryAfter time.Duration
+	if !job.Final() {
+		retryAfter = jobDeliverWebhook.Backoff(job.Attempt)
+	}
+	return ws.send(ctx, database.WebhookDelivery{
+		ID: d.ID,
+		WebhookID: d.WebhookID,
+		EventType: d.EventType,
+		Payload: d.Payload,
+	}, d.Url, d.Secret, retryAfter)
+}
+
+// send makes one attempt at a delivery and records the outcome. retryAfter
+// is when the next attempt will be if this one fails, or zero if this is the
+// last.
+func(ws *webhookSender) send(ctx context.Context, d database.WebhookDelivery, url, secret string, retryAfter time.Duration) error {
+	status, sendErr := ws.client.Send(ctx, webhook.Request{
+		URL: url,
+		Secret: secret,
+		DeliveryID: d.ID.String(),
+		Event: d.EventType,
+		Body: d.Payload,
+	})
+
+	params := database.RecordWebhookAttemptParams{
+		ID: d.ID,
+		Status: "succeeded",
+		ResponseStatus: sql.NullInt32{Int32: int32(status), Valid: status != 0},
+	}
+	outcome := "succeeded"
+	if sendErr != nil {
+		params.LastError = sql.NullString{String: truncate(sendErr.Error(), 500), Valid: true}
+		params.Status = "failed"
+		outcome = "failed"
+		if retryAfter > 0 {
+			params.Status = "pending"
+			params.RetrySeconds = sql.NullFloat64{Float64: retryAfter.Seconds(), Valid: true}
+			outcome = "retrying"
+		}
+	}
+	ws.metrics.outboundWebhooks.WithLabelValues(outcome).Inc()
+
+	if err := ws.db.RecordWebhookAttempt(ctx, params); err != nil {
+		slog.ErrorContext(ctx, "failed to record webhook delivery", "delivery_id", d.ID, "error", err)
+	}
+	return sendErr
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
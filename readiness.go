@@ -1,12 +1,90 @@
 package main
 
 import(
+	"context"
+	"log/slog"
 	"net/http"
+	"time"
 )
 
+const readinessTimeout = 2 * time.Second
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
+type dependencyStatus struct {
+	Status		string	`json:"status"`
+	LatencyMS	int64	`json:"latency_ms,omitempty"`
+	Version		*int64	`json:"version,omitempty"`
+	Expected	*int64	`json:"expected,omitempty"`
+}
+
+type readinessResponse struct {
+	Status	string						`json:"status"`
+	Checks	map[string]dependencyStatus	`json:"checks"`
+}
+
+func(cfg *apiConfig) handlerReadiness(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if cfg.draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("Draining"))
+			return
+		}
 		w.WriteHeader(200)
 		w.Write([]byte("OK"))
-	}
\ No newline at end of file
+	}
+
+// handlerLiveness only reports that the process is up and serving HTTP.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write([]byte("OK"))
+}
+
+// handlerReadyz checks every dependency needed to serve traffic and returns
+// 503 if any of them is unhealthy. The endpoint is public, so failures are
+// logged rather than returned.
+func(cfg *apiConfig) handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	response := readinessResponse{
+		Status: "ok",
+		Checks: map[string]dependencyStatus{},
+	}
+
+	if cfg.draining.Load() {
+		response.Checks["server"] = dependencyStatus{Status: "draining"}
+	} else {
+		response.Checks["server"] = dependencyStatus{Status: "ok"}
+	}
+
+	start := time.Now()
+	if err := cfg.dbConn.PingContext(ctx); err != nil {
+		slog.ErrorContext(ctx, "readiness check failed", "check", "database", "error", err)
+		response.Checks["database"] = dependencyStatus{Status: "unavailable"}
+	} else {
+		response.Checks["database"] = dependencyStatus{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+	}
+
+	response.Checks["migrations"] = cfg.checkMigrations(ctx)
+
+	code := http.StatusOK
+	for _, check := range response.Checks {
+		if check.Status != "ok" {
+			response.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	respondWithJSON(w, code, response)
+}
+
+func(cfg *apiConfig) checkMigrations(ctx context.Context) dependencyStatus {
+	version, expected, err := cfg.migrator.Versions(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "readiness check failed", "check", "migrations", "error", err)
+		return dependencyStatus{Status: "unavailable"}
+	}
+	if version != expected {
+		return dependencyStatus{Status: "mismatch", Version: &version, Expected: &expected}
+	}
+	return dependencyStatus{Status: "ok", Version: &version, Expected: &expected}
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// pathUUID parses the named path wildcard as a UUID.
+func pathUUID(r *http.Request, name string) (uuid.UUID, error) {
+	return uuid.Parse(r.PathValue(name))
+}
@@ -8,12 +8,14 @@ import (
 func(cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 
 	if cfg.platform != "dev" {
-		respondWithError(w, http.StatusForbidden, "Request is not using dev platform", fmt.Errorf("Request is not using dev platform"))
+		respondWithError(w, r, http.StatusForbidden, "Request is not using dev platform", fmt.Errorf("Request is not using dev platform"))
+		return
 	}
 	cfg.fileserverHits.Store(0)
 
 	if err := cfg.db.DeleteAllUsers(r.Context()); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "error resetting users database", err)
+		respondWithError(w, r, http.StatusInternalServerError, "error resetting users database", err)
+		return
 	}
 
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/auth"
+)
+
+type authUserKey struct{}
+
+type authUser struct {
+	ID   uuid.UUID
+	Role string
+}
+
+// middlewareRequireRole rejects requests whose access token doesn't carry at
+// least the required role. The authenticated user is available to next via
+// authUserFromContext.
+func(cfg *apiConfig) middlewareRequireRole(required string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
+			return
+		}
+
+		userID, role, err := validateJWTWithRole(r.Context(), token, cfg.secret)
+		if err != nil {
+			respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", err)
+			return
+		}
+
+		if !auth.HasRole(role, required) {
+			respondWithError(w, r, http.StatusForbidden, "Forbidden", nil)
+			return
+		}
+		if !cfg.requireNotSuspended(w, r, userID) {
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), authUserKey{}, authUser{ID: userID, Role: role})
+		next(w, r.WithContext(ctx))
+	})
+}
+
+func authUserFromContext(ctx context.Context) (authUser, bool) {
+	user, ok := ctx.Value(authUserKey{}).(authUser)
+	return user, ok
+}
+
+// optionalViewer returns the user making the request if it carries a valid
+// access token, for endpoints that are public but tailor what they show.
+func(cfg *apiConfig) optionalViewer(r *http.Request) uuid.NullUUID {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	id, err := validateJWT(r.Context(), token, cfg.secret)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
@@ -0,0 +1,21 @@
+-- name: CreateAuditEvent :exec
+INSERT INTO audit_events (action, actor_id, target_type, target_id, ip, user_agent, metadata)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg('actor_id')::uuid IS NULL OR actor_id = sqlc.narg('actor_id'))
+AND (sqlc.narg('action')::text IS NULL OR action = sqlc.narg('action'))
+AND (sqlc.narg('target_id')::text IS NULL OR target_id = sqlc.narg('target_id'))
+AND (sqlc.narg('since')::timestamp IS NULL OR created_at >= sqlc.narg('since'))
+AND (sqlc.narg('until')::timestamp IS NULL OR created_at < sqlc.narg('until'))
+ORDER BY id DESC
+LIMIT $1;
\ No newline at end of file
@@ -0,0 +1,101 @@
+-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, created_at, updated_at, user_id, name)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+ON CONFLICT (user_id, name) DO NOTHING
+RETURNING *;
+
+-- name: ListBookmarkCollections :many
+SELECT
+    bookmark_collections.*,
+    (
+        SELECT COUNT(*) FROM bookmarks
+        WHERE bookmarks.collection_id = bookmark_collections.id
+    ) AS bookmark_count
+FROM bookmark_collections
+WHERE bookmark_collections.user_id = $1
+ORDER BY bookmark_collections.name;
+
+-- name: CountBookmarkCollections :one
+SELECT COUNT(*) FROM bookmark_collections
+WHERE user_id = $1;
+
+-- name: GetBookmarkCollection :one
+SELECT * FROM bookmark_collections
+WHERE id = $1;
+
+-- name: RenameBookmarkCollection :one
+UPDATE bookmark_collections
+SET name = $2, updated_at = NOW()
+WHERE bookmark_collections.id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM bookmark_collections other
+    WHERE other.user_id = bookmark_collections.user_id
+    AND other.name = $2
+    AND other.id <> $1
+)
+RETURNING *;
+
+-- name: DeleteBookmarkCollection :exec
+DELETE FROM bookmark_collections
+WHERE id = $1;
+
+-- name: UpsertBookmark :one
+-- Bookmarking a chirp again moves it to the given collection.
+INSERT INTO bookmarks (user_id, chirp_id, collection_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, chirp_id) DO UPDATE
+SET collection_id = EXCLUDED.collection_id
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: ListBookmarks :many
+-- Bookmarked chirps the user can still see, most recently bookmarked first.
+SELECT
+    bookmarks.collection_id,
+    bookmarks.created_at AS bookmarked_at,
+    chirps.*
+FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg('user_id')
+AND chirps.hidden_at IS NULL
+AND (
+    sqlc.narg('collection_id')::uuid IS NULL
+    OR bookmarks.collection_id = sqlc.narg('collection_id')::uuid
+)
+AND (
+    sqlc.narg('before_created_at')::timestamp IS NULL
+    OR (bookmarks.created_at, bookmarks.chirp_id) < (
+        sqlc.narg('before_created_at')::timestamp,
+        sqlc.narg('before_chirp_id')::uuid
+    )
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND (
+        (user_id = chirps.user_id AND target_id = sqlc.arg('user_id'))
+        OR (user_id = sqlc.arg('user_id') AND target_id = chirps.user_id)
+    )
+)
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetBookmarkedChirpIDs :many
+-- The chirps among chirp_ids that the user has bookmarked.
+SELECT chirp_id FROM bookmarks
+WHERE user_id = sqlc.arg('user_id')
+AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: ExportBookmarksByUser :many
+SELECT * FROM bookmarks
+WHERE user_id = $1
+ORDER BY created_at ASC;
\ No newline at end of file
@@ -0,0 +1,49 @@
+-- name: ListChirpEvents :many
+-- Events written by transaction min_tx_id or later, in id order from
+-- after_id. author_limited is set while the author is limited, so that
+-- streams can hide their chirps from everyone else as GetAllChirps does.
+SELECT
+    chirp_events.id,
+    chirp_events.tx_id,
+    chirp_events.created_at,
+    chirp_events.type,
+    chirp_events.chirp_id,
+    chirp_events.user_id,
+    chirps.created_at AS chirp_created_at,
+    chirps.updated_at AS chirp_updated_at,
+    chirps.body AS chirp_body,
+    COALESCE(
+        users.status = 'limited' AND (users.status_until IS NULL OR users.status_until > NOW()),
+        false
+    )::boolean AS author_limited
+FROM chirp_events
+LEFT JOIN chirps ON chirps.id = chirp_events.chirp_id AND chirps.hidden_at IS NULL
+LEFT JOIN users ON users.id = chirp_events.user_id
+WHERE chirp_events.id > sqlc.arg('after_id')
+AND chirp_events.tx_id >= sqlc.arg('min_tx_id')
+ORDER BY chirp_events.id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: GetTxHorizon :one
+-- The oldest transaction still running. Any chirp event not visible yet
+-- was written by this transaction or a later one.
+SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint AS tx_horizon;
+
+-- name: GetChirpEventTxHorizon :one
+SELECT tx_horizon FROM chirp_events
+WHERE id = $1;
+
+-- name: DeleteChirpEventsOlderThan :execrows
+DELETE FROM chirp_events
+WHERE created_at < NOW() - make_interval(secs => sqlc.arg('age_seconds')::double precision);
+
+-- name: ListStreamHiddenAuthors :many
+-- Users whose chirps viewer_id shouldn't see live: anyone they've blocked or
+-- muted, and anyone who has blocked them.
+SELECT target_id AS user_id FROM user_relationships
+WHERE user_relationships.user_id = sqlc.arg('viewer_id')
+AND kind IN ('block', 'mute')
+UNION
+SELECT user_relationships.user_id FROM user_relationships
+WHERE target_id = sqlc.arg('viewer_id')
+AND kind = 'block';
\ No newline at end of file
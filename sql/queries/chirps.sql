@@ -10,8 +10,20 @@ VALUES (
 RETURNING *;
 
 -- name: GetAllChirps :many
-SELECT * FROM chirps
-ORDER BY created_at ASC;
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.hidden_at IS NULL
+AND (
+    users.status <> 'limited'
+    OR users.status_until <= NOW()
+    OR users.id = sqlc.narg('viewer_id')
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE (kind = 'block' AND user_id = chirps.user_id AND target_id = sqlc.narg('viewer_id'))
+    OR (kind IN ('block', 'mute') AND user_id = sqlc.narg('viewer_id') AND target_id = chirps.user_id)
+)
+ORDER BY chirps.created_at ASC;
 
 -- name: GetChirp :one
 SELECT * FROM chirps
@@ -22,6 +34,29 @@ DELETE FROM chirps
 WHERE id = $1;
 
 -- name: GetAllChirpsByUser :many
+SELECT chirps.* FROM chirps
+JOIN users ON users.id = chirps.user_id
+WHERE chirps.user_id = $1
+AND chirps.hidden_at IS NULL
+AND (
+    users.status <> 'limited'
+    OR users.status_until <= NOW()
+    OR users.id = sqlc.narg('viewer_id')
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE (kind = 'block' AND user_id = chirps.user_id AND target_id = sqlc.narg('viewer_id'))
+    OR (kind IN ('block', 'mute') AND user_id = sqlc.narg('viewer_id') AND target_id = chirps.user_id)
+)
+ORDER BY chirps.created_at ASC;
+
+-- name: ExportChirpsByUser :many
+-- Every chirp a user has posted, including hidden ones, for export-user.
 SELECT * FROM chirps
 WHERE user_id = $1
-ORDER BY created_at ASC;
\ No newline at end of file
+ORDER BY created_at ASC;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(), updated_at = NOW()
+WHERE id = $1;
\ No newline at end of file
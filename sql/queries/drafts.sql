@@ -0,0 +1,40 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
+
+-- name: ListDrafts :many
+SELECT * FROM drafts
+WHERE user_id = $1
+ORDER BY updated_at DESC;
+
+-- name: CountDrafts :one
+SELECT COUNT(*) FROM drafts
+WHERE user_id = $1;
+
+-- name: GetDraft :one
+SELECT * FROM drafts
+WHERE id = $1;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteDraft :exec
+DELETE FROM drafts
+WHERE id = $1;
+
+-- name: LockDraft :one
+-- Locks the draft until the end of the transaction, so it can only be
+-- published once.
+SELECT * FROM drafts
+WHERE id = $1
+FOR UPDATE;
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- name: ListFilterRules :many
+SELECT * FROM filter_rules
+ORDER BY word ASC;
+
+-- name: UpsertFilterRule :one
+INSERT INTO filter_rules (word, action, created_at, updated_at, created_by)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW(),
+    $3
+)
+ON CONFLICT (word) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE word = $1;
\ No newline at end of file
@@ -0,0 +1,87 @@
+-- name: EnqueueJob :exec
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, max_attempts, run_at, unique_key)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg('kind'),
+    sqlc.arg('payload'),
+    sqlc.arg('max_attempts'),
+    NOW() + make_interval(secs => sqlc.arg('delay_seconds')::double precision),
+    sqlc.narg('unique_key')
+)
+ON CONFLICT (unique_key) DO NOTHING;
+
+-- name: ClaimJobs :many
+-- Leases due jobs by marking them running until the lease runs out. A
+-- running job whose lease has run out was abandoned and is due again.
+UPDATE jobs
+SET status = 'running',
+    attempts = attempts + 1,
+    run_at = NOW() + make_interval(secs => sqlc.arg('lease_seconds')::double precision),
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE status IN ('pending', 'running')
+    AND run_at <= NOW()
+    AND kind = ANY(sqlc.arg('kinds')::text[])
+    ORDER BY run_at ASC
+    LIMIT sqlc.arg('batch_size')
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, kind, payload, attempts, max_attempts;
+
+-- name: CompleteJob :execrows
+-- The result updates, retry and bury below only apply to the attempt that
+-- holds the lease, so a worker that outlived its lease can't overwrite the
+-- job after it has been claimed again.
+UPDATE jobs
+SET status = 'succeeded', finished_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg('id') AND status = 'running' AND attempts = sqlc.arg('attempt');
+
+-- name: RetryJob :execrows
+UPDATE jobs
+SET status = 'pending',
+    run_at = NOW() + make_interval(secs => sqlc.arg('delay_seconds')::double precision),
+    last_error = sqlc.arg('last_error'),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND status = 'running' AND attempts = sqlc.arg('attempt');
+
+-- name: BuryJob :execrows
+UPDATE jobs
+SET status = 'dead', last_error = sqlc.arg('last_error'), finished_at = NOW(), updated_at = NOW()
+WHERE id = sqlc.arg('id') AND status = 'running' AND attempts = sqlc.arg('attempt');
+
+-- name: RequeueDeadJob :one
+-- Gives a dead job a fresh set of attempts.
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'dead'
+RETURNING *;
+
+-- name: GetJob :one
+SELECT * FROM jobs
+WHERE id = $1;
+
+-- name: DeleteJob :execrows
+DELETE FROM jobs
+WHERE id = $1 AND status IN ('succeeded', 'dead');
+
+-- name: ListJobs :many
+SELECT * FROM jobs
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+AND (sqlc.narg('kind')::text IS NULL OR kind = sqlc.narg('kind'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: CountJobsByKindAndStatus :many
+SELECT kind, status, COUNT(*) AS count FROM jobs
+GROUP BY kind, status
+ORDER BY kind, status;
+
+-- name: DeleteFinishedJobsOlderThan :execrows
+-- Dead jobs are kept longer than succeeded ones so there is time to look
+-- into them.
+DELETE FROM jobs
+WHERE (status = 'succeeded' AND finished_at < NOW() - make_interval(secs => sqlc.arg('succeeded_age_seconds')::double precision))
+OR (status = 'dead' AND finished_at < NOW() - make_interval(secs => sqlc.arg('dead_age_seconds')::double precision));
\ No newline at end of file
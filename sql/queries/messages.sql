@@ -0,0 +1,121 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1
+)
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: FindDirectConversation :one
+SELECT conversations.* FROM conversations
+JOIN conversation_members a ON a.conversation_id = conversations.id AND a.user_id = sqlc.arg('user_a')
+JOIN conversation_members b ON b.conversation_id = conversations.id AND b.user_id = sqlc.arg('user_b')
+WHERE (
+    SELECT COUNT(*) FROM conversation_members m
+    WHERE m.conversation_id = conversations.id
+) = 2
+LIMIT 1;
+
+-- name: GetConversationMembers :many
+SELECT user_id FROM conversation_members
+WHERE conversation_id = $1
+ORDER BY joined_at ASC, user_id ASC;
+
+-- name: ListConversationsForUser :many
+SELECT
+    conversations.*,
+    ARRAY(
+        SELECT m.user_id FROM conversation_members m
+        WHERE m.conversation_id = conversations.id
+        ORDER BY m.joined_at ASC, m.user_id ASC
+    )::uuid[] AS member_ids,
+    (
+        SELECT COUNT(*) FROM messages
+        WHERE messages.conversation_id = conversations.id
+        AND messages.sender_id IS DISTINCT FROM conversation_members.user_id
+        AND messages.created_at > COALESCE(conversation_members.last_read_at, '-infinity')
+        AND NOT EXISTS (
+            SELECT 1 FROM user_relationships
+            WHERE kind = 'block'
+            AND user_id = conversation_members.user_id
+            AND target_id = messages.sender_id
+        )
+    ) AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = $1
+ORDER BY conversations.updated_at DESC;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: ListMessages :many
+SELECT messages.* FROM messages
+WHERE messages.conversation_id = $1
+AND (
+    sqlc.narg('before')::uuid IS NULL
+    OR (messages.created_at, messages.id) < (
+        SELECT b.created_at, b.id FROM messages b
+        WHERE b.id = sqlc.narg('before')::uuid
+    )
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND user_id = sqlc.arg('viewer_id')
+    AND target_id = messages.sender_id
+)
+ORDER BY messages.created_at DESC, messages.id DESC
+LIMIT $2;
+
+-- name: MarkConversationRead :execrows
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1
+AND user_id = $2;
+
+-- name: CountUnreadMessages :one
+SELECT COUNT(*) FROM messages
+JOIN conversation_members ON conversation_members.conversation_id = messages.conversation_id
+WHERE conversation_members.user_id = $1
+AND messages.sender_id IS DISTINCT FROM conversation_members.user_id
+AND messages.created_at > COALESCE(conversation_members.last_read_at, '-infinity')
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND user_id = conversation_members.user_id
+    AND target_id = messages.sender_id
+);
+
+-- name: GetMessage :one
+SELECT * FROM messages
+WHERE id = $1;
+
+-- name: ExportMessagesBySender :many
+-- Only the user's own messages: what others wrote to them is theirs.
+SELECT * FROM messages
+WHERE sender_id = $1
+ORDER BY created_at ASC;
\ No newline at end of file
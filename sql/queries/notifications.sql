@@ -0,0 +1,87 @@
+-- name: CreateNotification :execrows
+-- Skips the insert if the recipient has turned this type off or has blocked
+-- the actor.
+INSERT INTO notifications (id, created_at, user_id, type, actor_id, subject_type, subject_id, group_key)
+SELECT
+    gen_random_uuid(),
+    NOW(),
+    sqlc.arg('user_id')::uuid,
+    sqlc.arg('type')::text,
+    sqlc.narg('actor_id')::uuid,
+    sqlc.arg('subject_type')::text,
+    sqlc.arg('subject_id')::text,
+    sqlc.arg('group_key')::text
+WHERE NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE notification_preferences.user_id = sqlc.arg('user_id')
+    AND notification_preferences.type = sqlc.arg('type')
+    AND NOT notification_preferences.enabled
+)
+AND NOT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE user_relationships.kind = 'block'
+    AND user_relationships.user_id = sqlc.arg('user_id')
+    AND user_relationships.target_id = sqlc.narg('actor_id')
+);
+
+-- name: ListNotificationGroups :many
+-- Unread notifications are grouped by subject; read ones by subject and the
+-- batch they were read in.
+SELECT
+    group_key,
+    type,
+    subject_type,
+    subject_id,
+    COUNT(*) AS count,
+    array_remove(array_agg(DISTINCT actor_id), NULL)::uuid[] AS actor_ids,
+    MAX(created_at)::timestamp AS latest_at,
+    read_at
+FROM notifications
+WHERE user_id = $1
+AND (NOT sqlc.arg('unread_only')::boolean OR read_at IS NULL)
+GROUP BY group_key, type, subject_type, subject_id, read_at
+HAVING sqlc.narg('before')::timestamp IS NULL OR MAX(created_at) < sqlc.narg('before')::timestamp
+ORDER BY latest_at DESC
+LIMIT $2;
+
+-- name: MarkNotificationGroupRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND group_key = $2
+AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(DISTINCT group_key) FROM notifications
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: SetNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled, updated_at = NOW();
+
+-- name: GetNotification :one
+SELECT * FROM notifications
+WHERE id = $1;
+
+-- name: ExportNotificationsByUser :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY created_at ASC;
\ No newline at end of file
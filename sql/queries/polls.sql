@@ -0,0 +1,53 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, created_at, closes_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW() + make_interval(secs => sqlc.arg('delay_seconds')::float8)
+);
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+VALUES ($1, $2, $3);
+
+-- name: GetPollResults :many
+-- One row per option of each poll on the given chirps, with its vote count
+-- and whether the viewer chose it.
+SELECT
+    polls.chirp_id,
+    polls.closes_at,
+    (polls.closes_at <= NOW())::boolean AS closed,
+    poll_options.position,
+    poll_options.text,
+    (
+        SELECT COUNT(*) FROM poll_votes
+        WHERE poll_votes.chirp_id = poll_options.chirp_id
+        AND poll_votes.position = poll_options.position
+    ) AS votes,
+    EXISTS (
+        SELECT 1 FROM poll_votes
+        WHERE poll_votes.chirp_id = poll_options.chirp_id
+        AND poll_votes.position = poll_options.position
+        AND poll_votes.user_id = sqlc.narg('viewer_id')
+    ) AS viewer_voted
+FROM polls
+JOIN poll_options ON poll_options.chirp_id = polls.chirp_id
+WHERE polls.chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY polls.chirp_id, poll_options.position;
+
+-- name: CastPollVote :execrows
+-- Records nothing if the user has already voted or the poll has closed.
+INSERT INTO poll_votes (chirp_id, user_id, position, created_at)
+SELECT polls.chirp_id, sqlc.arg('user_id'), sqlc.arg('position'), NOW()
+FROM polls
+WHERE polls.chirp_id = sqlc.arg('chirp_id')
+AND polls.closes_at > NOW()
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: ExportPollVotesByUser :many
+SELECT poll_votes.chirp_id, poll_votes.created_at, poll_options.text AS option
+FROM poll_votes
+JOIN poll_options ON poll_options.chirp_id = poll_votes.chirp_id
+    AND poll_options.position = poll_votes.position
+WHERE poll_votes.user_id = $1
+ORDER BY poll_votes.created_at ASC;
\ No newline at end of file
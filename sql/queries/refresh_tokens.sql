@@ -19,4 +19,26 @@ AND revoked_at IS NULL;
 -- name: UpdateRevoke :exec
 UPDATE refresh_tokens
 SET updated_at = NOW(), revoked_at = NOW()
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetRefreshTokensByUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteStaleRefreshTokens :execrows
+-- Deletes up to batch_size tokens that expired or were revoked more than
+-- retention_seconds ago.
+DELETE FROM refresh_tokens
+WHERE token IN (
+    SELECT token FROM refresh_tokens
+    WHERE expires_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::double precision)
+    OR revoked_at < NOW() - make_interval(secs => sqlc.arg('retention_seconds')::double precision)
+    LIMIT sqlc.arg('batch_size')
+);
\ No newline at end of file
@@ -0,0 +1,67 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, reported_user_id, chirp_id, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: ListReportsByReporter :many
+SELECT * FROM reports
+WHERE reporter_id = $1
+ORDER BY created_at DESC;
+
+-- name: ListReports :many
+SELECT * FROM reports
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2;
+
+-- name: ClaimReport :one
+UPDATE reports
+SET status = 'claimed', assignee_id = $2, updated_at = NOW()
+WHERE id = $1
+AND (status = 'open' OR (status = 'claimed' AND assignee_id = $2))
+RETURNING *;
+
+-- name: LockReport :one
+SELECT * FROM reports
+WHERE id = $1
+FOR UPDATE;
+
+-- name: ResolveReport :one
+UPDATE reports
+SET status = 'resolved',
+    resolution = $2,
+    assignee_id = COALESCE(assignee_id, sqlc.arg('moderator_id')),
+    resolved_by = sqlc.arg('moderator_id'),
+    resolved_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND status <> 'resolved'
+RETURNING *;
+
+-- name: CreateReportNote :one
+INSERT INTO report_notes (id, created_at, report_id, author_id, body)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: ListReportNotes :many
+SELECT * FROM report_notes
+WHERE report_id = $1
+ORDER BY created_at ASC;
\ No newline at end of file
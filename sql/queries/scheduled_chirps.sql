@@ -0,0 +1,57 @@
+-- name: CreateScheduledChirp :one
+INSERT INTO scheduled_chirps (id, created_at, updated_at, user_id, body, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    sqlc.arg('user_id'),
+    sqlc.arg('body'),
+    NOW() + make_interval(secs => sqlc.arg('delay_seconds')::double precision)
+)
+RETURNING *;
+
+-- name: ListScheduledChirps :many
+SELECT * FROM scheduled_chirps
+WHERE user_id = $1
+ORDER BY publish_at ASC;
+
+-- name: CountScheduledChirps :one
+SELECT COUNT(*) FROM scheduled_chirps
+WHERE user_id = $1;
+
+-- name: GetScheduledChirp :one
+SELECT * FROM scheduled_chirps
+WHERE id = $1;
+
+-- name: UpdateScheduledChirp :one
+-- Saving a scheduled chirp puts it back in the queue, so a failed one can
+-- be fixed and retried. publish_at is kept if delay_seconds is NULL.
+UPDATE scheduled_chirps
+SET body = sqlc.arg('body'),
+    publish_at = CASE
+        WHEN sqlc.narg('delay_seconds')::double precision IS NULL THEN publish_at
+        ELSE NOW() + make_interval(secs => sqlc.narg('delay_seconds')::double precision)
+    END,
+    status = 'pending',
+    error = NULL,
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: DeleteScheduledChirp :exec
+DELETE FROM scheduled_chirps
+WHERE id = $1;
+
+-- name: LockDueScheduledChirp :one
+-- Returns the scheduled chirp if it is due, locking it until the end of the
+-- transaction so that it can't be edited or published twice meanwhile.
+SELECT * FROM scheduled_chirps
+WHERE id = $1
+AND status = 'pending'
+AND publish_at <= NOW()
+FOR UPDATE;
+
+-- name: FailScheduledChirp :exec
+UPDATE scheduled_chirps
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE id = $1;
\ No newline at end of file
@@ -0,0 +1,46 @@
+-- name: CreateUserRelationship :exec
+INSERT INTO user_relationships (user_id, target_id, kind, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteUserRelationship :execrows
+DELETE FROM user_relationships
+WHERE user_id = $1
+AND target_id = $2
+AND kind = $3;
+
+-- name: ListUserRelationships :many
+SELECT * FROM user_relationships
+WHERE user_id = $1
+AND kind = $2
+ORDER BY created_at DESC;
+
+-- name: IsBlockedEitherWay :one
+SELECT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND (
+        (user_id = $1 AND target_id = $2)
+        OR (user_id = $2 AND target_id = $1)
+    )
+);
+
+-- name: IsBlockedWithAny :one
+SELECT EXISTS (
+    SELECT 1 FROM user_relationships
+    WHERE kind = 'block'
+    AND (
+        (user_id = sqlc.arg('user_id') AND target_id = ANY(sqlc.arg('other_ids')::uuid[]))
+        OR (target_id = sqlc.arg('user_id') AND user_id = ANY(sqlc.arg('other_ids')::uuid[]))
+    )
+);
+
+-- name: ExportUserRelationships :many
+SELECT * FROM user_relationships
+WHERE user_id = $1
+ORDER BY created_at ASC;
\ No newline at end of file
@@ -25,4 +25,44 @@ RETURNING *;
 -- name: UpgradeUser :exec
 UPDATE users
 SET is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: ListUsers :many
+SELECT * FROM users
+ORDER BY created_at ASC
+LIMIT $1 OFFSET $2;
+
+-- name: DeleteUser :exec
+DELETE FROM users
+WHERE id = $1;
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+
+-- name: SetUserRole :exec
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetUserStatus :one
+UPDATE users
+SET status = $2,
+    status_reason = sqlc.narg('status_reason'),
+    status_until = NOW() + make_interval(secs => sqlc.narg('duration_seconds')::double precision),
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: LiftExpiredUserStatus :one
+UPDATE users
+SET status = 'active', status_reason = NULL, status_until = NULL, updated_at = NOW()
+WHERE id = $1
+AND status_until <= NOW()
+RETURNING *;
\ No newline at end of file
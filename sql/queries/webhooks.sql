@@ -0,0 +1,94 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, created_at, updated_at, owner_id, global, url, secret, events)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks
+WHERE id = $1;
+
+-- name: ListWebhooksByOwner :many
+SELECT * FROM webhooks
+WHERE owner_id = $1
+ORDER BY created_at ASC;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = $2, events = $3, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CountWebhooksByOwner :one
+SELECT COUNT(*) FROM webhooks
+WHERE owner_id = $1;
+
+-- name: DeleteWebhook :exec
+DELETE FROM webhooks
+WHERE id = $1;
+
+-- name: EnqueueWebhookEvent :many
+-- Creates one delivery for every webhook subscribed to the event: global
+-- webhooks, and those owned by the user the event is about.
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_id, event_type, payload, next_attempt_at)
+SELECT
+    gen_random_uuid(),
+    NOW(),
+    webhooks.id,
+    sqlc.arg('event_id')::uuid,
+    sqlc.arg('event_type')::text,
+    sqlc.arg('payload')::jsonb,
+    NOW()
+FROM webhooks
+WHERE sqlc.arg('event_type')::text = ANY(webhooks.events)
+AND (webhooks.global OR webhooks.owner_id = sqlc.arg('subject_user_id')::uuid)
+RETURNING id;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, webhook_id, event_id, event_type, payload, next_attempt_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+)
+RETURNING *;
+
+-- name: GetWebhookDeliveryTarget :one
+SELECT webhook_deliveries.*, webhooks.url, webhooks.secret
+FROM webhook_deliveries
+JOIN webhooks ON webhooks.id = webhook_deliveries.webhook_id
+WHERE webhook_deliveries.id = $1;
+
+-- name: RecordWebhookAttempt :exec
+-- Records the outcome of an attempt. retry_seconds is when the next attempt
+-- is due, or NULL if there won't be one.
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    last_attempt_at = NOW(),
+    status = sqlc.arg('status'),
+    next_attempt_at = NOW() + make_interval(secs => COALESCE(sqlc.narg('retry_seconds')::double precision, 0)),
+    response_status = sqlc.narg('response_status'),
+    last_error = sqlc.narg('last_error')
+WHERE id = sqlc.arg('id');
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
\ No newline at end of file
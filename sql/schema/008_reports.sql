@@ -0,0 +1,43 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD COLUMN hidden_at TIMESTAMP;
+
+ALTER TABLE users
+ADD COLUMN status TEXT NOT NULL DEFAULT 'active'
+CHECK (status IN ('active', 'suspended'));
+
+CREATE TABLE reports (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    reporter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reported_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'claimed', 'resolved')),
+    assignee_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution TEXT
+        CHECK (resolution IN ('dismissed', 'chirp_hidden', 'user_suspended')),
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+CREATE TABLE report_notes (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    report_id UUID NOT NULL REFERENCES reports(id) ON DELETE CASCADE,
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    body TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE report_notes;
+DROP TABLE reports;
+
+ALTER TABLE users
+DROP COLUMN status;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
\ No newline at end of file
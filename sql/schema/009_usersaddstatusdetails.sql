@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE users
+DROP CONSTRAINT users_status_check;
+
+ALTER TABLE users
+ADD CONSTRAINT users_status_check
+CHECK (status IN ('active', 'limited', 'suspended'));
+
+ALTER TABLE users
+ADD COLUMN status_reason TEXT,
+ADD COLUMN status_until TIMESTAMP;
+
+-- +goose Down
+UPDATE users SET status = 'active' WHERE status = 'limited';
+
+ALTER TABLE users
+DROP COLUMN status_until,
+DROP COLUMN status_reason;
+
+ALTER TABLE users
+DROP CONSTRAINT users_status_check;
+
+ALTER TABLE users
+ADD CONSTRAINT users_status_check
+CHECK (status IN ('active', 'suspended'));
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE filter_rules (
+    word TEXT PRIMARY KEY,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+INSERT INTO filter_rules (word, action, created_at, updated_at)
+VALUES
+    ('kerfuffle', 'mask', NOW(), NOW()),
+    ('sharbert', 'mask', NOW(), NOW()),
+    ('fornax', 'mask', NOW(), NOW());
+
+-- Reports raised by the content filter have no reporter.
+ALTER TABLE reports
+ALTER COLUMN reporter_id DROP NOT NULL;
+
+-- +goose Down
+DELETE FROM reports WHERE reporter_id IS NULL;
+
+ALTER TABLE reports
+ALTER COLUMN reporter_id SET NOT NULL;
+
+DROP TABLE filter_rules;
\ No newline at end of file
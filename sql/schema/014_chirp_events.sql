@@ -0,0 +1,47 @@
+-- +goose Up
+-- chirp_events is the feed behind GET /api/stream. Triggers record every
+-- chirp that is created, deleted or hidden, and NOTIFY listening servers.
+CREATE TABLE chirp_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    type TEXT NOT NULL CHECK (type IN ('created', 'deleted')),
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL
+);
+
+CREATE INDEX chirp_events_created_at_idx ON chirp_events (created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION chirp_events_record() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO chirp_events (type, chirp_id, user_id)
+        VALUES ('created', NEW.id, NEW.user_id)
+        RETURNING id INTO event_id;
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO chirp_events (type, chirp_id, user_id)
+        VALUES ('deleted', OLD.id, OLD.user_id)
+        RETURNING id INTO event_id;
+    ELSIF OLD.hidden_at IS NULL AND NEW.hidden_at IS NOT NULL THEN
+        INSERT INTO chirp_events (type, chirp_id, user_id)
+        VALUES ('deleted', NEW.id, NEW.user_id)
+        RETURNING id INTO event_id;
+    ELSE
+        RETURN NULL;
+    END IF;
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_events_record
+AFTER INSERT OR UPDATE OF hidden_at OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION chirp_events_record();
+
+-- +goose Down
+DROP TRIGGER chirp_events_record ON chirps;
+DROP FUNCTION chirp_events_record();
+DROP TABLE chirp_events;
\ No newline at end of file
@@ -0,0 +1,34 @@
+-- +goose Up
+-- Tell listening servers about new messages and notifications, so they can
+-- be pushed to WebSocket subscribers connected to any instance.
+-- +goose StatementBegin
+CREATE FUNCTION live_events_notify() RETURNS trigger AS $$
+BEGIN
+    IF TG_TABLE_NAME = 'messages' THEN
+        PERFORM pg_notify('live_events', json_build_object(
+            'type', 'message',
+            'id', NEW.id
+        )::text);
+    ELSE
+        PERFORM pg_notify('live_events', json_build_object(
+            'type', 'notification',
+            'id', NEW.id
+        )::text);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER messages_live_events
+AFTER INSERT ON messages
+FOR EACH ROW EXECUTE FUNCTION live_events_notify();
+
+CREATE TRIGGER notifications_live_events
+AFTER INSERT ON notifications
+FOR EACH ROW EXECUTE FUNCTION live_events_notify();
+
+-- +goose Down
+DROP TRIGGER notifications_live_events ON notifications;
+DROP TRIGGER messages_live_events ON messages;
+DROP FUNCTION live_events_notify();
\ No newline at end of file
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    -- When a pending job is due, or when a running job's lease runs out.
+    run_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    finished_at TIMESTAMP,
+    unique_key TEXT UNIQUE
+);
+
+CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX jobs_kind_status_idx ON jobs (kind, status);
+
+-- Deliveries are now run as jobs.
+DROP INDEX webhook_deliveries_due_idx;
+
+-- +goose Down
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+DROP TABLE jobs;
\ No newline at end of file
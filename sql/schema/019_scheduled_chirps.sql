@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE scheduled_chirps (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    publish_at TIMESTAMP NOT NULL,
+    -- Rows are deleted once published. A chirp that can't be published,
+    -- e.g. because the content filter now rejects it, is kept as failed so
+    -- its author can edit it.
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'failed')),
+    error TEXT
+);
+
+CREATE INDEX scheduled_chirps_user_idx ON scheduled_chirps (user_id, publish_at);
+
+-- +goose Down
+DROP TABLE scheduled_chirps;
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE bookmark_collections (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+-- A user bookmarks a chirp at most once, in at most one collection.
+-- Deleting a collection keeps its bookmarks, outside any collection.
+CREATE TABLE bookmarks (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    collection_id UUID REFERENCES bookmark_collections(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_user_idx ON bookmarks (user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX bookmarks_collection_idx ON bookmarks (collection_id);
+
+-- +goose Down
+DROP TABLE bookmarks;
+DROP TABLE bookmark_collections;
\ No newline at end of file
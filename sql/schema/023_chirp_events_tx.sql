@@ -0,0 +1,18 @@
+-- +goose Up
+-- chirp_events ids come from a sequence when a row is inserted, but rows
+-- only become visible when their transaction commits, so a lower id can
+-- appear after a higher one. Readers can't use the id as a cursor. Instead
+-- each row records the transaction that wrote it (tx_id) and the oldest
+-- transaction still running at the time (tx_horizon). Every row that isn't
+-- visible yet belongs to a transaction no older than the current horizon.
+ALTER TABLE chirp_events
+    ADD COLUMN tx_id BIGINT NOT NULL DEFAULT pg_current_xact_id()::text::bigint,
+    ADD COLUMN tx_horizon BIGINT NOT NULL DEFAULT pg_snapshot_xmin(pg_current_snapshot())::text::bigint;
+
+CREATE INDEX chirp_events_tx_idx ON chirp_events (tx_id, id);
+
+-- +goose Down
+DROP INDEX chirp_events_tx_idx;
+ALTER TABLE chirp_events
+    DROP COLUMN tx_horizon,
+    DROP COLUMN tx_id;
\ No newline at end of file
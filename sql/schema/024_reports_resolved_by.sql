@@ -0,0 +1,13 @@
+-- +goose Up
+-- Who resolved a report, which isn't always who claimed it: admins can
+-- resolve reports another moderator has claimed.
+ALTER TABLE reports
+ADD COLUMN resolved_by UUID REFERENCES users(id) ON DELETE SET NULL;
+
+UPDATE reports
+SET resolved_by = assignee_id
+WHERE status = 'resolved';
+
+-- +goose Down
+ALTER TABLE reports
+DROP COLUMN resolved_by;
\ No newline at end of file
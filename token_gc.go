@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/ppllama/chirpy/internal/config"
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/jobs"
+)
+
+// Deleting in batches keeps each statement short, so a large backlog doesn't
+// hold locks on refresh_tokens for long.
+const refreshTokenGCBatch = 1000
+
+var jobPruneRefreshTokens = jobs.Kind[struct{}]{Name: "cleanup.refresh_tokens"}
+
+// pruneRefreshTokens deletes refresh tokens that expired or were revoked
+// more than retention ago, returning how many it deleted. They are kept for
+// a while first so that recent sessions can still be looked into.
+func pruneRefreshTokens(ctx context.Context, db *database.Queries, retention time.Duration) (int64, error) {
+	var total int64
+	for {
+		deleted, err := db.DeleteStaleRefreshTokens(ctx, database.DeleteStaleRefreshTokensParams{
+			RetentionSeconds: retention.Seconds(),
+			BatchSize: refreshTokenGCBatch,
+		})
+		total += deleted
+		if err != nil || deleted < refreshTokenGCBatch {
+			return total, err
+		}
+	}
+}
+
+func(cfg *apiConfig) pruneRefreshTokensJob(ctx context.Context, job jobs.Job, _ struct{}) error {
+	deleted, err := pruneRefreshTokens(ctx, cfg.db, cfg.refreshTokenRetention)
+	cfg.metrics.refreshTokensPruned.Add(float64(deleted))
+	return err
+}
+
+func runPruneTokens(appConfig *config.Config, args []string) error {
+	flags := flag.NewFlagSet("prune-tokens", flag.ContinueOnError)
+	retention := flags.Duration("retention", appConfig.RefreshTokenRetention, "keep tokens that expired or were revoked more recently than this")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *retention < 0 {
+		return fmt.Errorf("-retention must not be negative")
+	}
+
+	db, closeDB, err := openQueries(appConfig)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	deleted, err := pruneRefreshTokens(context.Background(), db, *retention)
+	fmt.Printf("Deleted %d refresh tokens\n", deleted)
+	return err
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+
+	"github.com/ppllama/chirpy/internal/database"
+	"github.com/ppllama/chirpy/internal/tracing"
+)
+
+// withTx runs fn with queries bound to a single transaction, committing if fn
+// returns nil and rolling back otherwise.
+func(cfg *apiConfig) withTx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(database.New(tracing.WrapDB(tx))); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
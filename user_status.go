@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ppllama/chirpy/internal/auth"
+	"github.com/ppllama/chirpy/internal/database"
+)
+
+// Account states. A limited user can still log in and post, but their chirps
+// are left out of everyone else's listings. A suspended user can't log in or
+// use the access tokens they already have.
+// Either can be timed, in which case it lifts on its own once status_until
+// has passed.
+const (
+	userStatusActive = "active"
+	userStatusLimited = "limited"
+	userStatusSuspended = "suspended"
+)
+
+func validUserStatus(status string) bool {
+	return status == userStatusActive || status == userStatusLimited || status == userStatusSuspended
+}
+
+// setUserStatus changes a user's status. A zero duration means until lifted.
+// Suspending a user also revokes their refresh tokens, so run it inside a
+// transaction when q is shared with other writes.
+func setUserStatus(ctx context.Context, q *database.Queries, userID uuid.UUID, status, reason string, duration time.Duration) error {
+	params := database.SetUserStatusParams{
+		ID: userID,
+		Status: status,
+	}
+	if status != userStatusActive {
+		params.StatusReason = sql.NullString{String: reason, Valid: reason != ""}
+		if duration > 0 {
+			params.DurationSeconds = sql.NullFloat64{Float64: duration.Seconds(), Valid: true}
+		}
+	}
+	if _, err := q.SetUserStatus(ctx, params); err != nil {
+		return err
+	}
+	if status == userStatusSuspended {
+		if _, err := q.RevokeUserRefreshTokens(ctx, userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liftExpiredStatus returns user with a timed status cleared if it has run
+// out. Expiry is checked lazily, whenever it matters, rather than by a sweeper.
+func(cfg *apiConfig) liftExpiredStatus(ctx context.Context, user database.User) (database.User, error) {
+	if user.Status == userStatusActive || !user.StatusUntil.Valid {
+		return user, nil
+	}
+	lifted, err := cfg.db.LiftExpiredUserStatus(ctx, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, nil
+	}
+	return lifted, err
+}
+
+// requireNotSuspended responds and returns false if the user an access token
+// was issued to has since been suspended or deleted. Suspension revokes
+// refresh tokens, but access tokens stay valid until they expire, so every
+// authenticated request checks.
+func(cfg *apiConfig) requireNotSuspended(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusUnauthorized, "Unauthorised", nil)
+		return false
+	}
+	if err == nil {
+		user, err = cfg.liftExpiredStatus(r.Context(), user)
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+		return false
+	}
+	if user.Status == userStatusSuspended {
+		respondWithError(w, r, http.StatusForbidden, suspendedMessage(user), nil)
+		return false
+	}
+	return true
+}
+
+var errStatusOwnAccount = errors.New("You can't change your own status")
+var errStatusNeedsAdmin = errors.New("Only admins can change the status of accounts with a role")
+
+// authorizeStatusChange checks that actor may change target's status, whether
+// directly or by resolving a report. Moderators can only act on plain users,
+// and nobody can act on themselves.
+func authorizeStatusChange(actor authUser, target database.User) error {
+	if target.ID == actor.ID {
+		return errStatusOwnAccount
+	}
+	if target.Role != auth.RoleUser && !auth.HasRole(actor.Role, auth.RoleAdmin) {
+		return errStatusNeedsAdmin
+	}
+	return nil
+}
+
+func suspendedMessage(user database.User) string {
+	if user.StatusUntil.Valid {
+		return "Account is suspended until " + user.StatusUntil.Time.Format(time.RFC3339)
+	}
+	return "Account is suspended"
+}
+
+// handlerSetUserStatus lets moderators limit, suspend or reinstate an account.
+// Moderators can only act on plain users; accounts with a role need an admin.
+func(cfg *apiConfig) handlerSetUserStatus(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Status		string	`json:"status"`
+		Reason		string	`json:"reason"`
+		Duration	string	`json:"duration"`
+	}
+
+	actor, _ := authUserFromContext(r.Context())
+
+	userID, err := pathUUID(r, "user_id")
+	if err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	params := parameters{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, r, http.StatusBadRequest, "Couldn't decode parameters", err)
+		return
+	}
+	if !validUserStatus(params.Status) {
+		respondWithError(w, r, http.StatusBadRequest, "status must be active, limited or suspended", nil)
+		return
+	}
+	params.Reason = strings.TrimSpace(params.Reason)
+	if params.Status != userStatusActive && params.Reason == "" {
+		respondWithError(w, r, http.StatusBadRequest, "A reason is required", nil)
+		return
+	}
+	var duration time.Duration
+	if params.Duration != "" {
+		duration, err = time.ParseDuration(params.Duration)
+		if err != nil || duration <= 0 {
+			respondWithError(w, r, http.StatusBadRequest, "duration must be a positive Go duration, e.g. 72h", err)
+			return
+		}
+	}
+
+	target, err := cfg.db.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, r, http.StatusNotFound, "User not found", nil)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+	switch err := authorizeStatusChange(actor, target); {
+	case errors.Is(err, errStatusOwnAccount):
+		respondWithError(w, r, http.StatusBadRequest, err.Error(), nil)
+		return
+	case err != nil:
+		respondWithError(w, r, http.StatusForbidden, err.Error(), nil)
+		return
+	}
+
+	err = cfg.withTx(r.Context(), func(q *database.Queries) error {
+		return setUserStatus(r.Context(), q, target.ID, params.Status, params.Reason, duration)
+	})
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't update status", err)
+		return
+	}
+
+	updated, err := cfg.db.GetUserByID(r.Context(), target.ID)
+	if err != nil {
+		respondWithError(w, r, http.StatusInternalServerError, "Couldn't get user", err)
+		return
+	}
+
+	metadata := map[string]any{"old_status": target.Status, "new_status": params.Status}
+	if params.Reason != "" {
+		metadata["reason"] = params.Reason
+	}
+	if duration > 0 {
+		metadata["duration"] = duration.String()
+	}
+	recordAudit(r.Context(), cfg.db, r, auditEntry{
+		Action: auditUserStatusChanged,
+		ActorID: actor.ID,
+		TargetType: "user",
+		TargetID: target.ID.String(),
+		Metadata: metadata,
+	})
+
+	respondWithJSON(w, http.StatusOK, userStatusFromDB(updated))
+}
+
+type UserStatus struct {
+	UserID	uuid.UUID	`json:"user_id"`
+	Status	string		`json:"status"`
+	Reason	string		`json:"reason,omitempty"`
+	Until	*time.Time	`json:"until,omitempty"`
+}
+
+func userStatusFromDB(user database.User) UserStatus {
+	status := UserStatus{
+		UserID: user.ID,
+		Status: user.Status,
+		Reason: user.StatusReason.String,
+	}
+	if user.StatusUntil.Valid {
+		status.Until = &user.StatusUntil.Time
+	}
+	return status
+}